@@ -10,9 +10,11 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
 	"gopkg.in/yaml.v2"
 )
@@ -20,12 +22,13 @@ import (
 // goal: generate maps of TSum for each crop, for each climate scenario
 // to check if the required TSum can be reached for each crop, for each climate scenario
 // it is assumed that the crop is sown at the earliest possible date and harvested at the latest possible date
-// this is supposed to work for summer crops, but not for winter crops
+// seasons are anchored at the sowing date and may cross the turn of the year (winter crops),
+// results are attributed to the harvest year
 // read weather files from climate scenarios
 // calculate TSum for each crop, with a given start date and end date
 // calculate maps for risks of frost and rain in the harvest period
 // required input of crop data:
-// - start date (DOY)
+// - start date (DOY), from sowing file or derived from weather (-sowing_rule)
 // - end date (DOY)
 // - TSum required to reach maturity
 // - base temperature
@@ -36,7 +39,10 @@ import (
 
 // required input of weather data:
 // - temperature (daily average)
+// - temperature (daily minimum and maximum, for sine and triangle thermal time methods)
 // - precipitation (daily total)
+// - missing values (empty, NA, -9999) are filled by a policy (-missing), or the season is marked invalid
+// - calendar standard, noleap (365_day) or 360_day (-calendar), sowing and harvest DOYs are mapped to the calendar
 // climate scenarios:
 // - historical
 // - RCP 4.5
@@ -49,13 +55,30 @@ import (
 // - maps of risks for each crop, for each climate scenario:
 //   - frost in growing period
 //   - rain in the harvest period
+// - maps of the inter-annual variability of TSum (standard deviation, coefficient of variation, quantiles)
+// - maps of the trend of TSum and frost days (Sen's slope, Mann-Kendall p-value)
+// - maps of the maturity date (average DOY, inter-annual standard deviation, days to latest harvest)
+// - optional: map of composite suitability classes, with rules from the crop file
+// - optional: map of the optimal sowing date within a sowing window (-optimize_sowing)
+// - optional: map of the latest safe sowing date within a sowing window (-latest_sowing)
+// subcommand validate-weather: quality check of all weather files of the reference file
 
 const defaultRefSize = 99367 // number of climate references from soybeanEU project
 
 func main() {
 
+	// subcommand: quality check of weather input
+	if len(os.Args) > 1 && os.Args[1] == "validate-weather" {
+		err := validateWeatherCommand(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	// parse crop from command line
 	cropFileName := flag.String("crop", "soybean.yml", "crop file name")
+	cropList := flag.String("crops", "", "comma separated list of crop files or a folder with crop files, each crop is written to its own output folder")
 	createCropFile := flag.Bool("create_crop", false, "create crop file")
 	sowingDateFile := flag.String("sowing", "sowing_date.csv", "sowing dates file name")
 	sowingDefaultDOY := flag.Int("sowing_default", 150, "default sowing date (DOY)")
@@ -63,10 +86,21 @@ func main() {
 	harvestDefaultDOY := flag.Int("harvest_default", 300, "default harvest date (DOY)")
 	startYear := flag.Int("start_year", 1981, "start year")
 	endYear := flag.Int("end_year", 2010, "end year")
-	pathToWeather := flag.String("weather", "weather", "path to weather files")
+	pathToWeather := flag.String("weather", "weather", "path to weather files, template with %s for the grid code, or NetCDF file(s) (*.nc)")
 	referenceFile := flag.String("reference", "stu_eu_layer_ref.csv", "reference file climate sowing date mapping")
 	gridToRefFile := flag.String("grid_to_ref", "stu_eu_layer_grid.csv", "grid to reference mapping file")
 	outputFolder := flag.String("output", "./output", "output folder")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of weather files processed in parallel")
+	manifestFile := flag.String("manifest", "", "run manifest file, runs all scenarios and crops declared in the manifest")
+	createManifestFile := flag.Bool("create_manifest", false, "create example run manifest file")
+	tsumQuantiles := flag.String("tsum_quantiles", "10,50,90", "quantiles of TSum (percent), written as grids and csv columns")
+	stageGrids := flag.Bool("stage_grids", false, "write mean DOY grids for each stage")
+	weatherFormatFile := flag.String("weather_format", "", "weather csv format file (yml), default SoybeanEU weather format")
+	sowingRule := flag.String("sowing_rule", "", "derive sowing dates from weather: earliest,latest,days,threshold,maxprecip (replaces sowing file)")
+	latestSowing := flag.String("latest_sowing", "", "search the latest safe sowing date per reference in a window: share,earliest,latest[,step] (share of years with maturity, DOY)")
+	optimizeSowing := flag.String("optimize_sowing", "", "search the optimal sowing date per reference in a window: earliest,latest[,step] (DOY)")
+	calendarName := flag.String("calendar", "", "calendar of the weather data: standard, noleap (365_day), 360_day (default standard, or the calendar of NetCDF files)")
+	missingPolicy := flag.String("missing", "", "fill missing weather values: derivetavg,interpolate=<days>,climatology,invalidyear (default missing values are an error)")
 
 	flag.Parse()
 
@@ -80,9 +114,95 @@ func main() {
 		}
 		return
 	}
+	if *createManifestFile {
+		err := generateManifestFile(*manifestFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	// read crop data from yml file
-	crop, err := readCropData(*cropFileName)
+	// quantiles of TSum
+	quantiles, err := parseQuantiles(*tsumQuantiles)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// sowing window for optimal sowing date
+	sowingWindow, err := parseSowingWindow(*optimizeSowing)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// search window for latest safe sowing date
+	latestSowingSearch, err := parseLatestSowing(*latestSowing)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// rule to derive sowing dates from weather
+	rule, err := parseSowingRule(*sowingRule)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// format of weather csv files
+	var weatherFormat *WeatherFormat
+	if *weatherFormatFile != "" {
+		weatherFormat, err = readWeatherFormat(*weatherFormatFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	// policies to fill missing weather values
+	missing, err := parseMissingPolicy(*missingPolicy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// calendar of the weather data
+	calendar, err := parseCalendar(*calendarName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *manifestFile != "" {
+		// run all scenarios and crops declared in the run manifest
+		manifest, err := readRunManifest(*manifestFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// optional outputs from command line are added to the outputs of the manifest
+		manifest.Outputs.StageGrids = manifest.Outputs.StageGrids || *stageGrids
+		if manifest.Outputs.TsumQuantiles == nil {
+			manifest.Outputs.TsumQuantiles = quantiles
+		}
+		if sowingWindow != nil {
+			manifest.Outputs.OptimizeSowing = sowingWindow
+		}
+		if latestSowingSearch != nil {
+			manifest.Outputs.LatestSowing = latestSowingSearch
+		}
+		// sowing rule from command line is used for scenarios without sowing rule
+		for i := range manifest.Scenarios {
+			if manifest.Scenarios[i].SowingRule == nil {
+				manifest.Scenarios[i].SowingRule = rule
+			}
+			if manifest.Scenarios[i].WeatherFormat == nil {
+				manifest.Scenarios[i].WeatherFormat = weatherFormat
+			}
+			if manifest.Scenarios[i].Missing == nil {
+				manifest.Scenarios[i].Missing = missing
+			}
+			if manifest.Scenarios[i].Calendar == "" {
+				manifest.Scenarios[i].Calendar = calendar
+			}
+		}
+		err = runManifest(manifest, *cropList, *workers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// read crop data from yml file(s)
+	crops, cropOutputFolders, err := readCrops(*cropFileName, *cropList, *outputFolder)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -92,38 +212,189 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	numberRef := len(referenceToGridCode)
 
-	// read time range data from csv file
-	timeRanges := readTimeRangeData(*sowingDateFile, *harvestDateFile, *sowingDefaultDOY, *harvestDefaultDOY, numberRef, *startYear, *endYear, crop.SowingDateAdjustment)
+	scenario := &Scenario{
+		Name:           "default",
+		Weather:        *pathToWeather,
+		Sowing:         *sowingDateFile,
+		Harvest:        *harvestDateFile,
+		StartYear:      *startYear,
+		EndYear:        *endYear,
+		SowingDefault:  *sowingDefaultDOY,
+		HarvestDefault: *harvestDefaultDOY,
+		SowingRule:     rule,
+		WeatherFormat:  weatherFormat,
+		Missing:        missing,
+		Calendar:       calendar,
+	}
+	// read latitude per reference, if required by a crop
+	latitudes, err := readLatitudesForCrops(crops, *referenceFile, *gridToRefFile, len(referenceToGridCode))
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := &OutputOptions{
+		TsumQuantiles:  quantiles,
+		StageGrids:     *stageGrids,
+		OptimizeSowing: sowingWindow,
+		LatestSowing:   latestSowingSearch,
+	}
+	err = runScenario(crops, cropOutputFolders, scenario, referenceToGridCode, gridCodeToReferences, latitudes, *gridToRefFile, *workers, options)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
-	// calculation result array
-	calculationResult := make([]*CalculationResultRef, numberRef)
-
-	for gridCode, refIds := range gridCodeToReferences {
-		// add weather grid code to path
-		weatherFileName := fmt.Sprintf(*pathToWeather, gridCode)
-		//check if file exists
-		if _, err := os.Stat(weatherFileName); os.IsNotExist(err) {
-			// file does not exist
-			log.Fatal(err)
+// optional outputs of a run
+type OutputOptions struct {
+	TsumQuantiles  []float64     `yaml:"tsumquantiles,omitempty"`  // quantiles of TSum (percent), default 10, 50, 90
+	StageGrids     bool          `yaml:"stagegrids,omitempty"`     // mean DOY grids for each stage
+	OptimizeSowing *SowingWindow `yaml:"optimizesowing,omitempty"` // search the optimal sowing date in a window
+	LatestSowing   *LatestSowing `yaml:"latestsowing,omitempty"`   // search the latest safe sowing date in a window
+
+	reportMissing bool // filled days and valid years in the csv output (scenarios with missing value policy)
+}
+
+// calculate a climate scenario for all crops and write the results to the output folder of each crop
+func runScenario(crops []*Crop, outputFolders []string, scenario *Scenario, referenceToGridCode []string, gridCodeToReferences map[string][]int, latitudes []float64, gridToRefFile string, workers int, options *OutputOptions) error {
+	numberRef := len(referenceToGridCode)
+
+	// read time range data from csv file, for each crop
+	// crops with the same sowing date adjustment share the same time ranges
+	timeRanges := make([][]*TimeRange, len(crops))
+	timeRangesByAdjustment := make(map[int][]*TimeRange)
+	for cropIdx, crop := range crops {
+		if _, ok := timeRangesByAdjustment[crop.SowingDateAdjustment]; !ok {
+			timeRangesByAdjustment[crop.SowingDateAdjustment] = readTimeRangeData(scenario.Sowing, scenario.Harvest, scenario.SowingDefault, scenario.HarvestDefault, numberRef, scenario.StartYear, scenario.EndYear, crop.SowingDateAdjustment)
 		}
+		timeRanges[cropIdx] = timeRangesByAdjustment[crop.SowingDateAdjustment]
+	}
+	// sowing dates derived from weather replace the sowing dates of the sowing file
+	sowing := newDerivedSowing(scenario.SowingRule, numberRef, scenario.EndYear-scenario.StartYear+1)
 
-		// open weather file and calculate TSum for crop, for each reference
-		calcResult, err := doCalculationPerWeatherFile(&crop, timeRanges, refIds, *startYear, *endYear, weatherFileName)
+	// weather source of the scenario
+	source, err := newWeatherSource(scenario.Weather, scenario.WeatherFormat, scenario.Calendar, newWeatherColumns(crops, scenario.Missing))
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	// sowing and harvest dates are mapped to the calendar of the weather data
+	for _, adjustedTimeRanges := range timeRangesByAdjustment {
+		source.Calendar().mapTimeRanges(adjustedTimeRanges)
+	}
+	// missing weather values are filled by the policy of the scenario
+	source = &filledWeatherSource{WeatherSource: source, crops: crops, policy: scenario.Missing}
+	scenarioOptions := *options
+	scenarioOptions.reportMissing = scenario.Missing != nil
+	options = &scenarioOptions
+
+	// calculate TSum for all weather files
+	calculationResult, err := calculateAllWeatherFiles(crops, timeRanges, gridCodeToReferences, latitudes, numberRef, scenario.StartYear, scenario.EndYear, source, workers, options, sowing)
+	if err != nil {
+		return err
+	}
+	// write calculation result to csv file and ascii grid, for each crop
+	for cropIdx := range crops {
+		err = writeCalculationResult(crops[cropIdx], calculationResult[cropIdx], referenceToGridCode, gridToRefFile, scenario.StartYear, scenario.EndYear, outputFolders[cropIdx], options)
 		if err != nil {
-			log.Fatal(err)
+			return err
+		}
+		if sowing != nil {
+			err = sowing.write(scenario.StartYear, scenario.EndYear, outputFolders[cropIdx])
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// weather file job for a worker
+type weatherJob struct {
+	gridCode string
+	refIds   []int
+}
+
+// calculation result of a weather file job
+type weatherJobResult struct {
+	gridCode string
+	results  [][]*CalculationResultRef // calculation result per crop, per reference
+	err      error
+}
+
+// calculate TSum for each crop and each weather file, weather files are processed in parallel by a pool of workers
+// returns calculation results per crop, indexed by refId-1
+func calculateAllWeatherFiles(crops []*Crop, timeRanges [][]*TimeRange, gridCodeToReferences map[string][]int, latitudes []float64, numberRef, startYear, endYear int, source WeatherSource, workers int, options *OutputOptions, sowing *derivedSowing) ([][]*CalculationResultRef, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	// sort grid codes, so that jobs are always processed in the same order
+	gridCodes := make([]string, 0, len(gridCodeToReferences))
+	for gridCode := range gridCodeToReferences {
+		gridCodes = append(gridCodes, gridCode)
+	}
+	sort.Strings(gridCodes)
+
+	jobs := make(chan weatherJob)
+	jobResults := make(chan weatherJobResult)
+	// closed on the first error, to stop feeding new jobs
+	stop := make(chan struct{})
+
+	// start workers
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				// read weather of grid code and calculate TSum for each crop, for each reference
+				calcResult, err := doCalculationPerWeatherFile(crops, timeRanges, job.refIds, latitudes, startYear, endYear, source, job.gridCode, options, sowing)
+				jobResults <- weatherJobResult{gridCode: job.gridCode, results: calcResult, err: err}
+			}
+		}()
+	}
+	// feed jobs to workers
+	go func() {
+		defer close(jobs)
+		for _, gridCode := range gridCodes {
+			select {
+			case jobs <- weatherJob{gridCode: gridCode, refIds: gridCodeToReferences[gridCode]}:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	// close result channel, when all workers are done
+	go func() {
+		wg.Wait()
+		close(jobResults)
+	}()
+
+	// calculation result arrays, only written by this goroutine
+	calculationResult := make([][]*CalculationResultRef, len(crops))
+	for cropIdx := range crops {
+		calculationResult[cropIdx] = make([]*CalculationResultRef, numberRef)
+	}
+	var firstErr error
+	for jobResult := range jobResults {
+		if jobResult.err != nil {
+			// keep the first error, but continue to drain the result channel
+			if firstErr == nil {
+				firstErr = fmt.Errorf("weather grid code %s: %w", jobResult.gridCode, jobResult.err)
+				close(stop)
+			}
+			continue
 		}
 		// store calculation result
-		for _, result := range calcResult {
-			calculationResult[result.refId-1] = result
+		for cropIdx, cropResults := range jobResult.results {
+			for _, result := range cropResults {
+				calculationResult[cropIdx][result.refId-1] = result
+			}
 		}
 	}
-	// write calculation result to csv file and ascii grid
-	err = writeCalculationResult(calculationResult, referenceToGridCode, *gridToRefFile, *startYear, *endYear, *outputFolder)
-	if err != nil {
-		log.Fatal(err)
+	if firstErr != nil {
+		return nil, firstErr
 	}
+	return calculationResult, nil
 }
 
 func generateCropFile(cropFileName string) error {
@@ -173,248 +444,478 @@ type CalculationResultRef struct {
 	TsumAvg          float64   // average TSum for all years
 	FrostOccurrence  int       // frost occurrence (number of years with frost)
 	WetHarvest       int       // number of years with wet harvest
+
+	filledDays   []int  // number of days with filled weather values in the season, for each year
+	invalidYears []bool // years with missing weather values in the season, excluded from the aggregated results
+	ValidYears   int    // number of years without missing weather values
+
+	maturityDoy       []int   // DOY of maturity for each year (valid, if TsumReached)
+	maturityMargin    []int   // days from maturity to latest harvest date for each year (valid, if TsumReached)
+	MaturityDoyAvg    float64 // average DOY of maturity (NaN, if maturity is never reached)
+	MaturityDoyStd    float64 // inter-annual standard deviation of the DOY of maturity
+	MaturityMarginAvg float64 // average days from maturity to latest harvest date
+
+	stageDoy    [][]int   // DOY at which each stage is reached, for each year (noDoy, if not reached)
+	StageDoyAvg []float64 // average DOY at which each stage is reached (NaN, if never reached)
+
+	TsumStd       float64   // inter-annual standard deviation of TSum
+	TsumCv        float64   // coefficient of variation of TSum (%)
+	TsumQuantiles []float64 // quantiles of TSum, for each configured quantile
+
+	TsumTrend   float64 // Sen's slope of TSum (per year)
+	TsumTrendP  float64 // p-value of the Mann-Kendall test of TSum
+	FrostTrend  float64 // Sen's slope of frost days (per year)
+	FrostTrendP float64 // p-value of the Mann-Kendall test of frost days
+
+	SuitableYears    int                   // number of years with TSum reached, without frost and without wet harvest
+	OptimalSowingDoy int                   // sowing date with the most suitable years (optimize sowing mode)
+	OptimalSowing    *CalculationResultRef // calculation result with the optimal sowing date
+	LatestSowingDoy  int                   // latest safe sowing date (latest sowing mode, noDoy if none)
+
+	Suitability int // suitability class (crops with suitability classification, 0 unsuitable)
+
+	stageFrostDays       [][]float64 // number of frost days for each stage, for each year
+	StageFrostOccurrence []int       // frost occurrence for each stage (number of years with frost in stage)
+
+	heatDays        []float64 // number of heat stress days for each year (crops with heat stress)
+	HeatStressYears []bool    // years with heat stress
+	HeatStress      int       // number of years with heat stress
+
+	waterDeficit      []float64   // seasonal water deficit (mm) for each year (crops with drought)
+	stageWaterDeficit [][]float64 // water deficit (mm) for each stage, for each year
+	DroughtYears      []bool      // years with drought
+	DroughtRisk       int         // number of years with drought
+
+	vernalizationDays         []float64 // vernalization days for each year (crops with vernalization)
+	VernalizationMissingYears []bool    // years with insufficient vernalization
+	VernalizationInsufficient int       // number of years with insufficient vernalization
+}
+
+// read weather of a grid code and calculate TSum for each crop, for each reference
+// the weather is read only once for all crops
+func doCalculationPerWeatherFile(crops []*Crop, timeRanges [][]*TimeRange, refIds []int, latitudes []float64, startYear, endYear int, source WeatherSource, gridCode string, options *OutputOptions, sowing *derivedSowing) ([][]*CalculationResultRef, error) {
+
+	// read previous year as well, for seasons crossing the turn of the year
+	weather, err := source.Read(gridCode, startYear-1, endYear)
+	if err != nil {
+		return nil, err
+	}
+	// check if weather file has tmax, if required by a thermal time method or heat stress
+	for _, crop := range crops {
+		if crop.requiresTmax() && !hasWeatherColumn(weather, colTmax) {
+			return nil, fmt.Errorf("crop %s requires tmax in the weather data", crop.Name)
+		}
+		if crop.Drought != nil && crop.Drought.Method == ET0PenmanMonteith && !hasPenmanMonteithColumns(weather) {
+			return nil, fmt.Errorf("crop %s requires globrad, wind and relhumid in the weather data for Penman-Monteith", crop.Name)
+		}
+	}
+	// sowing dates derived from weather
+	calendar := source.Calendar()
+	sowing.apply(crops, timeRanges, refIds, startYear, weather, calendar)
+	calculationResult := make([][]*CalculationResultRef, len(crops))
+	for cropIdx, crop := range crops {
+		calculationResult[cropIdx] = calculateCropPerWeather(crop, timeRanges[cropIdx], refIds, latitudes, startYear, endYear, weather, calendar, options)
+	}
+	return calculationResult, nil
 }
 
-func doCalculationPerWeatherFile(crop *Crop, timeRanges []*TimeRange, refIds []int, startYear, endYear int, weatherFileName string) ([]*CalculationResultRef, error) {
+// calculate TSum for a crop, for each reference sharing the same weather
+// latitudes (index refId-1) are only required for photoperiod sensitive crops and the water balance
+// each season is anchored at its sowing date and results are attributed to the harvest year
+func calculateCropPerWeather(crop *Crop, timeRanges []*TimeRange, refIds []int, latitudes []float64, startYear, endYear int, weather []weatherDay, calendar Calendar, options *OutputOptions) []*CalculationResultRef {
+
+	numberYears := endYear - startYear + 1
+	yearStart := yearStartIndex(weather)
+	setup := newSeasonSetup(crop, weather, calendar)
 
 	// calculation result array
 	calculationResult := make([]*CalculationResultRef, len(refIds))
-	refStages := make([]*refStage, len(refIds))
-	harvestRain := make([]*harvestRainDays, len(refIds))
 	for i, refId := range refIds {
-		calculationResult[i] = &CalculationResultRef{
-			refId:           refId,
-			Tsum:            make([]float64, endYear-startYear+1),
-			frostDays:       make([]float64, endYear-startYear+1),
-			TsumReached:     make([]bool, endYear-startYear+1),
-			WetHarvestYears: make([]bool, endYear-startYear+1),
+		setup.setReference(refId, latitudes)
+		calculationResult[i] = calculateReference(setup, timeRanges, refId, 0, startYear, numberYears, yearStart)
+		calculationResult[i].tsumStatistics(options.TsumQuantiles)
+		calculationResult[i].trends()
+		// search sowing date with the most suitable years
+		if options.OptimizeSowing != nil {
+			calculationResult[i].optimizeSowing(setup, options.OptimizeSowing, timeRanges, startYear, numberYears, yearStart)
 		}
-		refStages[i] = &refStage{
-			refId:    refId,
-			stageIdx: 0,
-			Tsum:     0,
+		// search latest sowing date with maturity in the required share of years
+		if options.LatestSowing != nil {
+			calculationResult[i].searchLatestSowing(setup, options.LatestSowing, timeRanges, startYear, numberYears, yearStart)
 		}
-		harvestRain[i] = newHarvestRainDays(refId)
 	}
-	// open weather file
-	weatherFile, err := os.Open(weatherFileName)
-	if err != nil {
-		return nil, err
+	return calculationResult
+}
+
+// calculate all seasons of a reference and aggregate the results
+// sowingDoy (standard calendar) overrides the sowing date of the time ranges, if > 0
+func calculateReference(setup *seasonSetup, timeRanges []*TimeRange, refId, sowingDoy, startYear, numberYears int, yearStart map[int]int) *CalculationResultRef {
+	result := newCalculationResultRef(setup.crop, refId, numberYears)
+	if sowingDoy > 0 {
+		sowingDoy = setup.calendar.fromStandardDoy(sowingDoy)
 	}
-	defer weatherFile.Close()
-	scanner := bufio.NewScanner(weatherFile)
-	headlines := 2
-	idxTavg := -1
-	idxTmin := -1
-	idxDate := -1
-	idxPrecip := -1
-	currentYear := -1
-	currentYearIdx := -1
-	for scanner.Scan() {
-		line := scanner.Text()
-		// parse header line and get index for tavg, tmin and date
-		if headlines > 0 {
-			fields := strings.Split(line, ",")
-			for idx, field := range fields {
-				if field == "tavg" {
-					idxTavg = idx
-				}
-				if field == "tmin" {
-					idxTmin = idx
-				}
-				if field == "iso-date" || field == "date" {
-					idxDate = idx
-				}
-				if field == "precip" {
-					idxPrecip = idx
-				}
-			}
-			headlines--
+	for yearIdx := 0; yearIdx < numberYears; yearIdx++ {
+		// seasons not covered by the weather data are invalid
+		season, ok := seasonDays(timeRanges, refId, sowingDoy, yearIdx, startYear, yearStart, len(setup.weather))
+		if !ok {
+			result.invalidYears[yearIdx] = true
 			continue
 		}
-		// split line
-		fields := strings.Split(line, ",")
-		// parse date
-		date := fields[idxDate]
-		year, err := strconv.Atoi(date[0:4])
-		if err != nil {
-			return nil, err
+		// seasons with missing weather values are invalid
+		complete, filledDays := setup.checkSeason(season)
+		result.filledDays[yearIdx] = filledDays
+		if !complete {
+			result.invalidYears[yearIdx] = true
+			continue
 		}
-		// check if year is in range
-		if year < startYear {
+		calculateSeason(setup, season, result, yearIdx)
+	}
+	result.aggregate(setup.crop, numberYears)
+	return result
+}
+
+// aggregate yearly results of a reference, invalid years are excluded
+// tsum reached maturity
+// avg tsum
+// frost occurrence
+func (result *CalculationResultRef) aggregate(crop *Crop, numberYears int) {
+	for yearIdx := 0; yearIdx < numberYears; yearIdx++ {
+		if result.invalidYears[yearIdx] {
 			continue
 		}
-		if year > endYear {
-			break
-		}
-		// check if year has changed
-		if year != currentYear {
-			currentYear = year
-			currentYearIdx++
-			// reset stage index and TSum for each reference
-			for _, rs := range refStages {
-				rs.stageIdx = 0
-				rs.Tsum = 0
-			}
-			// reset harvest date for each reference
-			for _, hr := range harvestRain {
-				hr.harvestDoy = -1
-				hr.numWetHarvest = 0
+		result.ValidYears++
+		// tsum reached maturity
+		if result.Tsum[yearIdx] >= crop.TsumMaturity {
+			result.TsumReached[yearIdx] = true
+		}
+		// avg TSum
+		result.TsumAvg += result.Tsum[yearIdx]
+		// frost occurrence
+		if result.frostDays[yearIdx] > 0 {
+			result.FrostOccurrence++
+		}
+		// frost occurrence per stage
+		for stageIdx, stageFrostDays := range result.stageFrostDays {
+			if stageFrostDays[yearIdx] > 0 {
+				result.StageFrostOccurrence[stageIdx]++
 			}
 		}
-		// get doy from date
-		// convert date to DOY
-		dateTime, err := time.Parse("2006-01-02", date)
-		if err != nil {
-			return nil, err
+		// number of years TSum reached maturity
+		if result.TsumReached[yearIdx] {
+			result.TsumReachedCount++
 		}
-		doy := dateTime.YearDay()
-
-		// parse avgerage temperature
-		tavg, err := strconv.ParseFloat(fields[idxTavg], 64)
-		if err != nil {
-			return nil, err
+		// number of years with wet harvest
+		if result.WetHarvestYears[yearIdx] {
+			result.WetHarvest++
 		}
-		// parse minimum temperature
-		tmin, err := strconv.ParseFloat(fields[idxTmin], 64)
-		if err != nil {
-			return nil, err
+		// number of years suitable for the crop
+		if result.TsumReached[yearIdx] && result.frostDays[yearIdx] == 0 && !result.WetHarvestYears[yearIdx] {
+			result.SuitableYears++
 		}
-		precip, err := strconv.ParseFloat(fields[idxPrecip], 64)
-		if err != nil {
-			return nil, err
+		// number of years with heat stress
+		if crop.HeatStress != nil && result.heatDays[yearIdx] > 0 {
+			result.HeatStressYears[yearIdx] = true
+			result.HeatStress++
+		}
+		// number of years with drought
+		if crop.Drought != nil && result.waterDeficit[yearIdx] > crop.Drought.MaxDeficit {
+			result.DroughtYears[yearIdx] = true
+			result.DroughtRisk++
 		}
-		// calculate TSum for each crop, for each reference
-		for idx, refId := range refIds {
+		// number of years with insufficient vernalization
+		if crop.Vernalization != nil && result.vernalizationDays[yearIdx] < crop.Vernalization.RequiredDays {
+			result.VernalizationMissingYears[yearIdx] = true
+			result.VernalizationInsufficient++
+		}
+	}
+	// avg TSum, NaN without valid years
+	if result.ValidYears > 0 {
+		result.TsumAvg /= float64(result.ValidYears)
+	} else {
+		result.TsumAvg = math.NaN()
+	}
+	// suitability class
+	if crop.Suitability != nil {
+		result.Suitability = crop.Suitability.classify(result, result.ValidYears)
+	}
+	// maturity and stage date statistics, over years with maturity or stage reached
+	result.aggregateMaturity()
+	result.aggregateStageDoy()
+}
 
-			// count wet harvest days before doy check if crop is in season
-			// harvest may be after end of season
-			calcHarRain := harvestRain[idx].countWetHarvestDays(doy, precip)
-			if calcHarRain && harvestRain[idx].numWetHarvest > 0 {
-				calculationResult[idx].WetHarvestYears[currentYearIdx] = true
-			}
+// index of the first weather day of each year
+func yearStartIndex(weather []weatherDay) map[int]int {
+	yearStart := make(map[int]int)
+	for dayIdx, day := range weather {
+		if _, ok := yearStart[day.year]; !ok {
+			yearStart[day.year] = dayIdx - (day.doy - 1)
+		}
+	}
+	return yearStart
+}
 
-			// check if date is in vegetation period / time range
-			if doy < timeRanges[year-startYear].StartDOY[refId-1] || doy > timeRanges[year-startYear].EndDOY[refId-1] {
-				continue
-			}
+// weather day indices of a season
+type seasonRange struct {
+	first      int // sowing day
+	last       int // last day of the season, latest harvest date limited to the weather data
+	harvestIdx int // latest harvest date, may be after the weather data
+	yearStart  int // first day of the harvest year
+}
 
-			// calculate TSum for each crop, for each reference
-			tsum := calculateTSum(refStages[idx], crop, tavg)
-			// calculate stage for crop
-			calcStage(refStages[idx], crop, tsum)
-			calculationResult[idx].Tsum[currentYearIdx] += tsum
-			// set harvest date
-			if harvestRain[idx].harvestDoy <= 0 && calculationResult[idx].Tsum[currentYearIdx] >= crop.TsumMaturity {
-				harvestRain[idx].harvestDoy = doy
-			}
-			// calculate frost days
-			if tmin < crop.FrostTreashold &&
-				calculationResult[idx].Tsum[currentYearIdx] > 0 &&
-				calculationResult[idx].Tsum[currentYearIdx] < crop.TsumMaturity {
-				calculationResult[idx].frostDays[currentYearIdx]++
-			}
-		}
+// first and last weather day index of the season harvested in year startYear+yearIdx
+// if the sowing DOY is after the harvest DOY, the season starts in the previous year,
+// with the sowing DOY of the previous year (or of the harvest year, if the previous year is not in the time range)
+// the season is invalid (not ok), if the sowing date is not in the weather data
+// sowingDoy overrides the sowing date of the time ranges, if > 0
+func seasonDays(timeRanges []*TimeRange, refId, sowingDoy, yearIdx, startYear int, yearStart map[int]int, numberDays int) (season seasonRange, ok bool) {
+	harvestYear := startYear + yearIdx
+	fixedSowing := sowingDoy > 0
+	if !fixedSowing {
+		sowingDoy = timeRanges[yearIdx].StartDOY[refId-1]
 	}
-	// for each reference
-	// tsum reached maturity
-	// avg tsum
-	// frost occurrence
-	for idx := range refIds {
-		for yearIdx := 0; yearIdx < endYear-startYear+1; yearIdx++ {
-			// tsum reached maturity
-			if calculationResult[idx].Tsum[yearIdx] >= crop.TsumMaturity {
-				calculationResult[idx].TsumReached[yearIdx] = true
-			}
-			// avg TSum
-			calculationResult[idx].TsumAvg += calculationResult[idx].Tsum[yearIdx]
-			// frost occurrence
-			if calculationResult[idx].frostDays[yearIdx] > 0 {
-				calculationResult[idx].FrostOccurrence++
-			}
-			// number of years TSum reached maturity
-			if calculationResult[idx].TsumReached[yearIdx] {
-				calculationResult[idx].TsumReachedCount++
-			}
-			// number of years with wet harvest
-			if calculationResult[idx].WetHarvestYears[yearIdx] {
-				calculationResult[idx].WetHarvest++
-			}
+	harvestDoy := timeRanges[yearIdx].EndDOY[refId-1]
+	harvestYearStart, ok := yearStart[harvestYear]
+	if !ok {
+		return season, false
+	}
+	first := 0
+	last := harvestYearStart + harvestDoy - 1
+	season.harvestIdx = last
+	season.yearStart = harvestYearStart
+	if sowingDoy <= harvestDoy {
+		// season within harvest year
+		first = harvestYearStart + sowingDoy - 1
+	} else {
+		// season crosses the turn of the year
+		if yearIdx > 0 && !fixedSowing {
+			sowingDoy = timeRanges[yearIdx-1].StartDOY[refId-1]
 		}
-		// avg TSum
-		calculationResult[idx].TsumAvg /= float64(endYear - startYear + 1)
+		sowingYearStart, found := yearStart[harvestYear-1]
+		if !found {
+			return season, false
+		}
+		first = sowingYearStart + sowingDoy - 1
 	}
-	return calculationResult, nil
+	if first < 0 {
+		return season, false
+	}
+	if last >= numberDays {
+		last = numberDays - 1
+	}
+	season.first = first
+	season.last = last
+	return season, first <= last
 }
 
-type refStage struct {
-	refId    int
-	stageIdx int
-	Tsum     float64
+// settings of a season calculation, derived from crop parameters, weather and reference
+type seasonSetup struct {
+	crop               *Crop
+	weather            []weatherDay
+	vernalizationStage int    // stage delayed until vernalization is completed (-1 without vernalization)
+	heatStages         []bool // stages of the heat stress window (nil without heat stress)
+	penmanMonteith     bool   // reference evapotranspiration with Penman-Monteith, otherwise Hargreaves
+	harvestRain        *HarvestRainRule
+	calendar           Calendar  // calendar of the weather data
+	missingColumns     []int     // weather columns with missing values, checked for each season
+	latitude           float64   // latitude of the reference (NaN, if not required)
+	daylengths         []float64 // daylength per DOY of the reference (photoperiod sensitive crops)
 }
 
-func calculateTSum(rs *refStage, c *Crop, tavg float64) float64 {
-	// calculate temperature sum for crop
-	temp := tavg - c.Stages[rs.stageIdx].BaseTemp
-	if temp < 0 {
-		temp = 0
+// create season setup for a crop and the weather of a weather file
+func newSeasonSetup(crop *Crop, weather []weatherDay, calendar Calendar) *seasonSetup {
+	setup := &seasonSetup{
+		crop:               crop,
+		weather:            weather,
+		calendar:           calendar,
+		vernalizationStage: -1,
+		harvestRain:        crop.harvestRainRule(),
+		latitude:           math.NaN(),
+	}
+	// stage delayed until vernalization is completed
+	if crop.Vernalization != nil {
+		setup.vernalizationStage, _ = crop.Vernalization.stageIndex(crop.Stages)
 	}
-	return temp
+	// stages in heat stress window
+	if crop.HeatStress != nil {
+		setup.heatStages, _ = crop.HeatStress.stageWindow(crop.Stages)
+	}
+	// evapotranspiration method
+	setup.penmanMonteith = crop.usesPenmanMonteith(weather)
+	setup.missingColumns = missingSeasonColumns(crop, weather, setup.penmanMonteith)
+	return setup
 }
 
-func calcStage(rs *refStage, c *Crop, tsumDay float64) {
-	rs.Tsum += tsumDay
-	// calculate stage for crop
-	if rs.stageIdx+1 == len(c.Stages) {
+// set reference specific settings
+func (setup *seasonSetup) setReference(refId int, latitudes []float64) {
+	if latitudes == nil {
 		return
 	}
-	if rs.Tsum >= c.Stages[rs.stageIdx].Tsum {
-		rs.stageIdx++
-		rs.Tsum = 0
+	setup.latitude = latitudes[refId-1]
+	// daylength per DOY, for photoperiod sensitive crops
+	if setup.crop.usesPhotoperiod() {
+		setup.daylengths = daylengthTable(setup.latitude)
 	}
 }
 
-type harvestRainDays struct {
-	refId          int
-	harvestDoy     int
-	precipPrevDays dataLastDays
-	numWetHarvest  int
-}
-
-func newHarvestRainDays(refId int) *harvestRainDays {
-	return &harvestRainDays{
-		refId:          refId,
-		harvestDoy:     -1,
-		precipPrevDays: newDataLastDays(15),
-		numWetHarvest:  0,
+// create calculation result for a reference
+func newCalculationResultRef(crop *Crop, refId, numberYears int) *CalculationResultRef {
+	result := &CalculationResultRef{
+		refId:           refId,
+		Tsum:            make([]float64, numberYears),
+		frostDays:       make([]float64, numberYears),
+		TsumReached:     make([]bool, numberYears),
+		WetHarvestYears: make([]bool, numberYears),
+		maturityDoy:     make([]int, numberYears),
+		maturityMargin:  make([]int, numberYears),
+		filledDays:      make([]int, numberYears),
+		invalidYears:    make([]bool, numberYears),
+	}
+	result.stageFrostDays = make([][]float64, len(crop.Stages))
+	for stageIdx := range crop.Stages {
+		result.stageFrostDays[stageIdx] = make([]float64, numberYears)
+	}
+	result.StageFrostOccurrence = make([]int, len(crop.Stages))
+	result.stageDoy = make([][]int, len(crop.Stages))
+	for stageIdx := range crop.Stages {
+		result.stageDoy[stageIdx] = make([]int, numberYears)
+		for yearIdx := range result.stageDoy[stageIdx] {
+			result.stageDoy[stageIdx][yearIdx] = noDoy
+		}
+	}
+	if crop.HeatStress != nil {
+		result.heatDays = make([]float64, numberYears)
+		result.HeatStressYears = make([]bool, numberYears)
+	}
+	if crop.Drought != nil {
+		result.waterDeficit = make([]float64, numberYears)
+		result.stageWaterDeficit = make([][]float64, len(crop.Stages))
+		for stageIdx := range crop.Stages {
+			result.stageWaterDeficit[stageIdx] = make([]float64, numberYears)
+		}
+		result.DroughtYears = make([]bool, numberYears)
 	}
+	if crop.Vernalization != nil {
+		result.vernalizationDays = make([]float64, numberYears)
+		result.VernalizationMissingYears = make([]bool, numberYears)
+	}
+	return result
 }
 
-func (hw *harvestRainDays) countWetHarvestDays(doy int, precip float64) (calculated bool) {
+// calculate a season from sowing to latest harvest
+func calculateSeason(setup *seasonSetup, season seasonRange, result *CalculationResultRef, yearIdx int) {
+	crop := setup.crop
+	weather := setup.weather
+	rs := &refStage{
+		refId:    result.refId,
+		stageIdx: 0,
+		Tsum:     0,
+	}
+	maturityIdx := -1
+	for dayIdx := season.first; dayIdx <= season.last; dayIdx++ {
+		day := weather[dayIdx]
+		// solar position (daylength, radiation) of the standard DOY
+		day.doy = setup.calendar.toStandardDoy(day.doy)
+		// minimum temperature of the next day, for double sine method
+		tminNext := day.tmin
+		if dayIdx+1 < len(weather) {
+			tminNext = weather[dayIdx+1].tmin
+		}
 
-	calculated = false
-	hw.precipPrevDays.addDay(precip)
-	// has harvest date been reached and this doy is harvest + offs
-	if hw.harvestDoy > 0 && doy == hw.harvestDoy+10 {
-		wetDayCounter := 0
-		twoDryDaysInRowDry := false
-		rainData := hw.precipPrevDays.getData() // get last 15 days
-		for i, x := range rainData {
-			if i > 4 && x > 0 {
-				wetDayCounter++
-			}
-			if i > 4 && x == 0 && rainData[i-1] == 0 {
-				twoDryDaysInRowDry = true
+		// accumulate vernalization days
+		if crop.Vernalization != nil {
+			if crop.Vernalization.isVernalizationDay(day.tavg) {
+				rs.vernalizationDays++
 			}
+			result.vernalizationDays[yearIdx] = rs.vernalizationDays
+		}
+
+		// calculate TSum for crop
+		tsum := calculateTSum(rs, crop, day, tminNext)
+		// development rate is reduced by the photoperiod factor of the current stage
+		if setup.daylengths != nil {
+			tsum *= crop.Stages[rs.stageIdx].Photoperiod.factor(setup.daylengths[day.doy])
 		}
-		if wetDayCounter >= 5 && !twoDryDaysInRowDry {
-			hw.numWetHarvest++
+		// no development of the vernalization stage, until vernalization is completed
+		if setup.vernalizationStage >= 0 && rs.stageIdx >= setup.vernalizationStage &&
+			rs.vernalizationDays < crop.Vernalization.RequiredDays {
+			tsum = 0
 		}
-		calculated = true
+		// calculate stage for crop
+		dayStageIdx := rs.stageIdx
+		matureBefore := maturityIdx >= 0
+		calcStage(rs, crop, tsum)
+		result.Tsum[yearIdx] += tsum
+		// stage reached, when the TSum of the stage is completed
+		if result.stageDoy[dayStageIdx][yearIdx] == noDoy &&
+			(rs.stageIdx > dayStageIdx || rs.Tsum >= crop.Stages[dayStageIdx].Tsum) {
+			result.stageDoy[dayStageIdx][yearIdx] = setup.calendar.toStandardDoy(dayIdx - season.yearStart + 1)
+		}
+		// set maturity date
+		if maturityIdx < 0 && result.Tsum[yearIdx] >= crop.TsumMaturity {
+			maturityIdx = dayIdx
+		}
+		// calculate frost days, with frost threshold of the stage of this day
+		frostThreshold, frostSensitive := crop.frostThreshold(dayStageIdx)
+		if frostSensitive &&
+			day.tmin < frostThreshold &&
+			result.Tsum[yearIdx] > 0 &&
+			result.Tsum[yearIdx] < crop.TsumMaturity {
+			result.frostDays[yearIdx]++
+			result.stageFrostDays[dayStageIdx][yearIdx]++
+		}
+		// calculate heat stress days, within heat stress window before maturity
+		if setup.heatStages != nil &&
+			setup.heatStages[dayStageIdx] &&
+			day.tmax > crop.HeatStress.Threshold &&
+			result.Tsum[yearIdx] < crop.TsumMaturity {
+			result.heatDays[yearIdx]++
+		}
+		// climatic water balance from sowing to maturity
+		if crop.Drought != nil && !matureBefore {
+			deficit := crop.Stages[dayStageIdx].cropCoefficient()*referenceET(day, setup.latitude, setup.penmanMonteith) - day.precip
+			result.waterDeficit[yearIdx] += deficit
+			result.stageWaterDeficit[dayStageIdx][yearIdx] += deficit
+		}
+	}
+	// maturity date, DOY of the harvest year (0 or negative, if matured in the sowing year)
+	// and days from maturity to the latest harvest date
+	if maturityIdx >= 0 {
+		result.maturityDoy[yearIdx] = setup.calendar.toStandardDoy(maturityIdx - season.yearStart + 1)
+		result.maturityMargin[yearIdx] = season.harvestIdx - maturityIdx
 	}
-	return calculated
+	// wet harvest after maturity, harvest may be after end of season
+	if maturityIdx >= 0 && setup.harvestRain.isWetHarvest(weather, maturityIdx) {
+		result.WetHarvestYears[yearIdx] = true
+	}
+}
+
+type refStage struct {
+	refId             int
+	stageIdx          int
+	Tsum              float64
+	vernalizationDays float64
 }
 
+func calculateTSum(rs *refStage, c *Crop, day weatherDay, tminNext float64) float64 {
+	// calculate temperature sum for crop, with the thermal time method of the crop
+	// and the response function of the current stage
+	return dailyThermalTime(c.ThermalTimeMethod, &c.Stages[rs.stageIdx], day.tavg, day.tmin, day.tmax, tminNext)
+}
+
+func calcStage(rs *refStage, c *Crop, tsumDay float64) {
+	rs.Tsum += tsumDay
+	// calculate stage for crop
+	if rs.stageIdx+1 == len(c.Stages) {
+		return
+	}
+	if rs.Tsum >= c.Stages[rs.stageIdx].Tsum {
+		rs.stageIdx++
+		rs.Tsum = 0
+	}
+}
+
+// time range per year (index year-startYear)
+// if StartDOY > EndDOY, the season is sown in the previous year and harvested in this year
 type TimeRange struct {
 	StartDOY []int // start date (DOY) - earliest possible sowing date
 	EndDOY   []int // end date (DOY) - latest possible harvest date
@@ -426,14 +927,27 @@ type Crop struct {
 
 	TsumMaturity         float64 // TSum required to reach maturity
 	Stages               []Stage
-	FrostTreashold       float64 // temperature below which frost occurs
-	SowingDateAdjustment int     // number of days to add or substract to sowing date
+	FrostTreashold       float64          // temperature below which frost occurs
+	SowingDateAdjustment int              // number of days to add or substract to sowing date
+	ThermalTimeMethod    string           `yaml:"thermaltimemethod,omitempty"` // tavg (default), averaging, single_sine, double_sine or single_triangle
+	Vernalization        *Vernalization   `yaml:"vernalization,omitempty"`     // optional vernalization requirement (winter crops)
+	HeatStress           *HeatStress      `yaml:"heatstress,omitempty"`        // optional heat stress indicator
+	Drought              *Drought         `yaml:"drought,omitempty"`           // optional water balance and drought risk
+	HarvestRain          *HarvestRainRule `yaml:"harvestrain,omitempty"`       // optional wet harvest rule, default SoybeanEU rule
+	Suitability          *Suitability     `yaml:"suitability,omitempty"`       // optional composite suitability classification
 }
 
 type Stage struct {
-	Name     string  // optional
-	Tsum     float64 // TSum required to reach this stage
-	BaseTemp float64 // base temperature for this stage
+	Name        string        // optional
+	Tsum        float64       // TSum required to reach this stage
+	BaseTemp    float64       // base temperature for this stage
+	Response    *TempResponse `yaml:"response,omitempty"`    // optional temperature response function, default linear
+	Photoperiod *Photoperiod  `yaml:"photoperiod,omitempty"` // optional photoperiod sensitivity
+
+	FrostThreshold   *float64 `yaml:"frostthreshold,omitempty"`   // optional frost threshold for this stage, default crop frost threshold
+	FrostInsensitive bool     `yaml:"frostinsensitive,omitempty"` // stage is not sensitive to frost
+
+	Kc *float64 `yaml:"kc,omitempty"` // crop coefficient for this stage, default 1 (crops with drought)
 }
 
 // read crop data from yml file
@@ -456,9 +970,117 @@ func readCropData(filename string) (crop Crop, err error) {
 	if err != nil {
 		return crop, err
 	}
+	if len(crop.Stages) == 0 {
+		return crop, fmt.Errorf("crop %s has no stages", crop.Name)
+	}
+	if err := validateThermalTimeMethod(crop.ThermalTimeMethod); err != nil {
+		return crop, fmt.Errorf("crop %s: %w", crop.Name, err)
+	}
+	if crop.Vernalization != nil {
+		if _, err := crop.Vernalization.validate(crop.Stages); err != nil {
+			return crop, fmt.Errorf("crop %s: %w", crop.Name, err)
+		}
+	}
+	if crop.HeatStress != nil {
+		if _, err := crop.HeatStress.stageWindow(crop.Stages); err != nil {
+			return crop, fmt.Errorf("crop %s: %w", crop.Name, err)
+		}
+	}
+	if crop.Drought != nil {
+		if err := crop.Drought.validate(); err != nil {
+			return crop, fmt.Errorf("crop %s: %w", crop.Name, err)
+		}
+	}
+	if crop.HarvestRain != nil {
+		if err := crop.HarvestRain.validate(); err != nil {
+			return crop, fmt.Errorf("crop %s: %w", crop.Name, err)
+		}
+	}
+	if crop.Suitability != nil {
+		if err := crop.Suitability.validate(&crop); err != nil {
+			return crop, fmt.Errorf("crop %s: %w", crop.Name, err)
+		}
+	}
+	for _, stage := range crop.Stages {
+		if stage.Response != nil {
+			if err := stage.Response.validate(stage.BaseTemp); err != nil {
+				return crop, fmt.Errorf("crop %s, stage %s: %w", crop.Name, stage.Name, err)
+			}
+		}
+		if stage.Photoperiod != nil {
+			if err := stage.Photoperiod.validate(); err != nil {
+				return crop, fmt.Errorf("crop %s, stage %s: %w", crop.Name, stage.Name, err)
+			}
+		}
+		if stage.Kc != nil && *stage.Kc < 0 {
+			return crop, fmt.Errorf("crop %s, stage %s: kc %v must not be negative", crop.Name, stage.Name, *stage.Kc)
+		}
+	}
 	return crop, nil
 }
 
+// read crops from a single crop file or from a list of crop files
+// cropList is a comma separated list of crop files and/or folders containing crop files (*.yml, *.yaml)
+// if cropList is empty, the single crop file is used and written to the output folder,
+// otherwise each crop is written to a sub folder of the output folder, named after the crop file
+func readCrops(cropFileName, cropList, outputFolder string) (crops []*Crop, outputFolders []string, err error) {
+	if cropList == "" {
+		crop, err := readCropData(cropFileName)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*Crop{&crop}, []string{outputFolder}, nil
+	}
+
+	cropFiles := make([]string, 0)
+	for _, entry := range strings.Split(cropList, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		info, err := os.Stat(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !info.IsDir() {
+			cropFiles = append(cropFiles, entry)
+			continue
+		}
+		// add all crop files in folder
+		dirEntries, err := os.ReadDir(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, dirEntry := range dirEntries {
+			ext := filepath.Ext(dirEntry.Name())
+			if dirEntry.IsDir() || (ext != ".yml" && ext != ".yaml") {
+				continue
+			}
+			cropFiles = append(cropFiles, filepath.Join(entry, dirEntry.Name()))
+		}
+	}
+	if len(cropFiles) == 0 {
+		return nil, nil, fmt.Errorf("no crop files found in %s", cropList)
+	}
+
+	usedNames := make(map[string]string)
+	for _, cropFile := range cropFiles {
+		crop, err := readCropData(cropFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", cropFile, err)
+		}
+		// output folder named after crop file, without extension
+		name := strings.TrimSuffix(filepath.Base(cropFile), filepath.Ext(cropFile))
+		if other, ok := usedNames[name]; ok {
+			return nil, nil, fmt.Errorf("crop files %s and %s would write to the same output folder", other, cropFile)
+		}
+		usedNames[name] = cropFile
+		crops = append(crops, &crop)
+		outputFolders = append(outputFolders, filepath.Join(outputFolder, name))
+	}
+	return crops, outputFolders, nil
+}
+
 // read time range data from csv file
 func readTimeRangeData(sowingDateFile, harvestDateFile string, sowingDateDefault, harvestDefault, size, startYear, endYear, sowingDateAdjustment int) (timeRanges []*TimeRange) {
 
@@ -608,7 +1230,7 @@ func readClimateRefData(filename string) (referenceToWeatherGridCode []string, G
 }
 
 // write calculation result to csv file and ascii grid
-func writeCalculationResult(calculationResult []*CalculationResultRef, referenceToClim []string, gridToRefFile string, startYear, endYear int, outpuFolder string) error {
+func writeCalculationResult(crop *Crop, calculationResult []*CalculationResultRef, referenceToClim []string, gridToRefFile string, startYear, endYear int, outpuFolder string, options *OutputOptions) error {
 	// write calculation result to csv file
 	csvFileName := filepath.Join(outpuFolder, fmt.Sprintf("cal_res_ref_%d-%d.csv", startYear, endYear))
 	csvFile, err := createGzFileWriter(csvFileName)
@@ -616,20 +1238,82 @@ func writeCalculationResult(calculationResult []*CalculationResultRef, reference
 		return err
 	}
 	defer csvFile.Close()
-	// write header line
-	_, err = csvFile.Write("refId,climate,year,Tsum,frost_days,Tsum_reached,Wet_Harvest\n")
+	// write header line, optional columns for crop modules
+	header := "refId,climate,year,Tsum,frost_days,Tsum_reached,Wet_Harvest,maturity_doy"
+	if crop.hasStageOutput() {
+		for stageIdx := range crop.Stages {
+			header += ",frost_days_" + stageLabel(crop.Stages, stageIdx)
+		}
+	}
+	if crop.HeatStress != nil {
+		header += ",heat_days"
+	}
+	if crop.Drought != nil {
+		header += ",water_deficit"
+		if crop.hasStageOutput() {
+			for stageIdx := range crop.Stages {
+				header += ",water_deficit_" + stageLabel(crop.Stages, stageIdx)
+			}
+		}
+	}
+	if crop.Vernalization != nil {
+		header += ",vernalization_days,vernalization_insufficient"
+	}
+	if options.reportMissing {
+		header += ",filled_days,valid"
+	}
+	_, err = csvFile.Write(header + "\n")
 	if err != nil {
 		return err
 	}
 
 	for _, result := range calculationResult {
 		for yearIdx := 0; yearIdx < endYear-startYear+1; yearIdx++ {
-			_, err = csvFile.Write(fmt.Sprintf("%d,%s,%d,%f,%f,%t,%t\n", result.refId, referenceToClim[result.refId-1], startYear+yearIdx, result.Tsum[yearIdx], result.frostDays[yearIdx], result.TsumReached[yearIdx], result.WetHarvestYears[yearIdx]))
+			line := fmt.Sprintf("%d,%s,%d,%f,%f,%t,%t", result.refId, referenceToClim[result.refId-1], startYear+yearIdx, result.Tsum[yearIdx], result.frostDays[yearIdx], result.TsumReached[yearIdx], result.WetHarvestYears[yearIdx])
+			// maturity date, -9999 if maturity is not reached
+			if result.TsumReached[yearIdx] {
+				line += fmt.Sprintf(",%d", result.maturityDoy[yearIdx])
+			} else {
+				line += ",-9999"
+			}
+			if crop.hasStageOutput() {
+				for _, stageFrostDays := range result.stageFrostDays {
+					line += fmt.Sprintf(",%f", stageFrostDays[yearIdx])
+				}
+			}
+			if crop.HeatStress != nil {
+				line += fmt.Sprintf(",%f", result.heatDays[yearIdx])
+			}
+			if crop.Drought != nil {
+				line += fmt.Sprintf(",%f", result.waterDeficit[yearIdx])
+				if crop.hasStageOutput() {
+					for _, stageWaterDeficit := range result.stageWaterDeficit {
+						line += fmt.Sprintf(",%f", stageWaterDeficit[yearIdx])
+					}
+				}
+			}
+			if crop.Vernalization != nil {
+				line += fmt.Sprintf(",%f,%t", result.vernalizationDays[yearIdx], result.VernalizationMissingYears[yearIdx])
+			}
+			if options.reportMissing {
+				line += fmt.Sprintf(",%d,%t", result.filledDays[yearIdx], !result.invalidYears[yearIdx])
+			}
+			_, err = csvFile.Write(line + "\n")
 			if err != nil {
 				return err
 			}
 		}
 	}
+	// write TSum statistics to csv file
+	err = writeTsumStatistics(calculationResult, referenceToClim, options.TsumQuantiles, startYear, endYear, outpuFolder)
+	if err != nil {
+		return err
+	}
+	// write stage dates to csv file
+	err = writeStageDoy(crop, calculationResult, referenceToClim, startYear, endYear, outpuFolder)
+	if err != nil {
+		return err
+	}
 	// load grid to reference mapping
 	rowExt, colExt, gridToRef, err := GetGridLookup(gridToRefFile)
 	if err != nil {
@@ -637,14 +1321,14 @@ func writeCalculationResult(calculationResult []*CalculationResultRef, reference
 	}
 
 	// --------------------
-	writeGrid := func(ascFileNameTempl string, outType outputType) error {
+	writeGrid := func(ascFileNameTempl string, value gridValue) error {
 		ascFileName := filepath.Join(outpuFolder, fmt.Sprintf(ascFileNameTempl, startYear, endYear))
 		fout, err := createGridFile(ascFileName, colExt, rowExt)
 		if err != nil {
 			return err
 		}
 		defer fout.Close()
-		err = writeRows(fout, rowExt, colExt, calculationResult, outType, gridToRef)
+		err = writeRows(fout, rowExt, colExt, calculationResult, value, gridToRef)
 		if err != nil {
 			return err
 		}
@@ -653,25 +1337,151 @@ func writeCalculationResult(calculationResult []*CalculationResultRef, reference
 
 	// write calculation result to ascii grids
 	// TsumAvg
-	err = writeGrid("TsumAvg_%d-%d.asc", TSumAvg)
+	err = writeGrid("TsumAvg_%d-%d.asc", TSumAvg.value)
+	if err != nil {
+		return err
+	}
+	// TSum statistics
+	err = writeGrid("TsumSd_%d-%d.asc", TSumStd.value)
+	if err != nil {
+		return err
+	}
+	err = writeGrid("TsumCv_%d-%d.asc", TSumCv.value)
+	if err != nil {
+		return err
+	}
+	// trend of TSum and frost days
+	err = writeGrid("TsumTrend_%d-%d.asc", TSumTrend.value)
+	if err != nil {
+		return err
+	}
+	err = writeGrid("TsumTrendP_%d-%d.asc", TSumTrendP.value)
 	if err != nil {
 		return err
 	}
+	err = writeGrid("FrostTrend_%d-%d.asc", FrostTrend.value)
+	if err != nil {
+		return err
+	}
+	err = writeGrid("FrostTrendP_%d-%d.asc", FrostTrendP.value)
+	if err != nil {
+		return err
+	}
+	for qIdx, q := range options.TsumQuantiles {
+		qIdx := qIdx
+		err = writeGrid("Tsum"+quantileLabel(q)+"_%d-%d.asc", func(result *CalculationResultRef) string {
+			return roundedValue(result.TsumQuantiles[qIdx])
+		})
+		if err != nil {
+			return err
+		}
+	}
 	// TsumReached
-	err = writeGrid("TsumReached_%d-%d.asc", TSumReached)
+	err = writeGrid("TsumReached_%d-%d.asc", TSumReached.value)
 	if err != nil {
 		return err
 	}
 	// FrostOccurrence
-	err = writeGrid("FrostOccurrence_%d-%d.asc", FrostOccurrence)
+	err = writeGrid("FrostOccurrence_%d-%d.asc", FrostOccurrence.value)
 	if err != nil {
 		return err
 	}
+	// FrostOccurrence per stage
+	if crop.hasStageOutput() {
+		for stageIdx := range crop.Stages {
+			stageIdx := stageIdx
+			err = writeGrid("FrostOccurrence_"+stageLabel(crop.Stages, stageIdx)+"_%d-%d.asc", func(result *CalculationResultRef) string {
+				return strconv.Itoa(result.StageFrostOccurrence[stageIdx])
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
 	// WetHarvest
-	err = writeGrid("WetHarvest_%d-%d.asc", WetHarvest)
+	err = writeGrid("WetHarvest_%d-%d.asc", WetHarvest.value)
+	if err != nil {
+		return err
+	}
+	// maturity date
+	err = writeGrid("MaturityDoyAvg_%d-%d.asc", MaturityDoyAvg.value)
+	if err != nil {
+		return err
+	}
+	err = writeGrid("MaturityDoyStd_%d-%d.asc", MaturityDoyStd.value)
+	if err != nil {
+		return err
+	}
+	err = writeGrid("MaturityMargin_%d-%d.asc", MaturityMargin.value)
 	if err != nil {
 		return err
 	}
+	// mean DOY per stage
+	if options.StageGrids {
+		for stageIdx := range crop.Stages {
+			stageIdx := stageIdx
+			err = writeGrid("StageDoy_"+stageLabel(crop.Stages, stageIdx)+"_%d-%d.asc", func(result *CalculationResultRef) string {
+				return roundedValue(result.StageDoyAvg[stageIdx])
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	// HeatStress
+	if crop.HeatStress != nil {
+		err = writeGrid("HeatStress_%d-%d.asc", HeatStressOccurrence.value)
+		if err != nil {
+			return err
+		}
+	}
+	// DroughtRisk
+	if crop.Drought != nil {
+		err = writeGrid("DroughtRisk_%d-%d.asc", DroughtRisk.value)
+		if err != nil {
+			return err
+		}
+	}
+	// VernalizationInsufficient
+	if crop.Vernalization != nil {
+		err = writeGrid("VernalizationInsufficient_%d-%d.asc", VernalizationInsufficient.value)
+		if err != nil {
+			return err
+		}
+	}
+	// Suitability
+	if crop.Suitability != nil {
+		err = writeGrid("Suitability_%d-%d.asc", SuitabilityClassValue.value)
+		if err != nil {
+			return err
+		}
+		err = crop.Suitability.writeLegend(outpuFolder)
+		if err != nil {
+			return err
+		}
+	}
+	// latest safe sowing date
+	if options.LatestSowing != nil {
+		err = writeGrid("LatestSowingDoy_%d-%d.asc", LatestSowingDoy.value)
+		if err != nil {
+			return err
+		}
+	}
+	// optimal sowing date
+	if options.OptimizeSowing != nil {
+		err = writeOptimalSowing(calculationResult, referenceToClim, startYear, endYear, outpuFolder)
+		if err != nil {
+			return err
+		}
+		err = writeGrid("OptimalSowingDoy_%d-%d.asc", OptimalSowingDoy.value)
+		if err != nil {
+			return err
+		}
+		err = writeGrid("OptimalSowingSuitable_%d-%d.asc", OptimalSowingSuitable.value)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -708,27 +1518,122 @@ const (
 	FrostOccurrence
 	// output type for WetHarvest
 	WetHarvest
+	// output type for VernalizationInsufficient
+	VernalizationInsufficient
+	// output type for HeatStress
+	HeatStressOccurrence
+	// output type for DroughtRisk
+	DroughtRisk
+	// output type for average maturity DOY
+	MaturityDoyAvg
+	// output type for standard deviation of maturity DOY
+	MaturityDoyStd
+	// output type for average days from maturity to latest harvest
+	MaturityMargin
+	// output type for optimal sowing date
+	OptimalSowingDoy
+	// output type for number of suitable years with optimal sowing date
+	OptimalSowingSuitable
+	// output type for latest safe sowing date
+	LatestSowingDoy
+	// output type for standard deviation of TSum
+	TSumStd
+	// output type for coefficient of variation of TSum
+	TSumCv
+	// output type for suitability class
+	SuitabilityClassValue
+	// output type for trend of TSum
+	TSumTrend
+	// output type for p-value of trend of TSum
+	TSumTrendP
+	// output type for trend of frost days
+	FrostTrend
+	// output type for p-value of trend of frost days
+	FrostTrendP
 )
 
-func writeRows(fout *Fout, extRow, extCol int, calcResults []*CalculationResultRef, outType outputType, gridSourceLookup [][]int) error {
+// value of a calculation result written to a grid cell
+type gridValue func(result *CalculationResultRef) string
+
+// grid value for output type
+func (outType outputType) value(result *CalculationResultRef) string {
+	switch outType {
+	case TSumAvg:
+		return roundedValue(result.TsumAvg)
+	case TSumReached:
+		return strconv.Itoa(result.TsumReachedCount)
+	case FrostOccurrence:
+		return strconv.Itoa(result.FrostOccurrence)
+	case WetHarvest:
+		return strconv.Itoa(result.WetHarvest)
+	case VernalizationInsufficient:
+		return strconv.Itoa(result.VernalizationInsufficient)
+	case HeatStressOccurrence:
+		return strconv.Itoa(result.HeatStress)
+	case DroughtRisk:
+		return strconv.Itoa(result.DroughtRisk)
+	case MaturityDoyAvg:
+		return roundedValue(result.MaturityDoyAvg)
+	case MaturityDoyStd:
+		return floatValue(result.MaturityDoyStd, 1)
+	case MaturityMargin:
+		return roundedValue(result.MaturityMarginAvg)
+	case OptimalSowingDoy:
+		return strconv.Itoa(result.OptimalSowingDoy)
+	case OptimalSowingSuitable:
+		if result.OptimalSowing == nil {
+			return "-9999"
+		}
+		return strconv.Itoa(result.OptimalSowing.SuitableYears)
+	case LatestSowingDoy:
+		return strconv.Itoa(result.LatestSowingDoy)
+	case SuitabilityClassValue:
+		if result.ValidYears == 0 {
+			return "-9999"
+		}
+		return strconv.Itoa(result.Suitability)
+	case TSumStd:
+		return roundedValue(result.TsumStd)
+	case TSumCv:
+		return floatValue(result.TsumCv, 1)
+	case TSumTrend:
+		return floatValue(result.TsumTrend, 2)
+	case TSumTrendP:
+		return floatValue(result.TsumTrendP, 4)
+	case FrostTrend:
+		return floatValue(result.FrostTrend, 3)
+	case FrostTrendP:
+		return floatValue(result.FrostTrendP, 4)
+	}
+	return "-9999"
+}
+
+// rounded grid value, NODATA for NaN
+func roundedValue(v float64) string {
+	if math.IsNaN(v) {
+		return "-9999"
+	}
+	return strconv.Itoa(int(math.Round(v)))
+}
+
+// grid value with decimals, NODATA for NaN
+func floatValue(v float64, decimals int) string {
+	if math.IsNaN(v) {
+		return "-9999"
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// write grid rows, cells without a reference (refId 1 to number of results) are NODATA
+func writeRows(fout *Fout, extRow, extCol int, calcResults []*CalculationResultRef, value gridValue, gridSourceLookup [][]int) error {
 	size := len(calcResults)
 	for row := 0; row < extRow; row++ {
 
 		for col := 0; col < extCol; col++ {
 			refID := gridSourceLookup[row][col]
 			var err error
-			if refID >= 0 && refID < size {
-				if outType == TSumAvg {
-					_, err = fout.Write(strconv.Itoa(int(math.Round(calcResults[refID-1].TsumAvg))))
-				} else if outType == TSumReached {
-					_, err = fout.Write(strconv.Itoa(calcResults[refID-1].TsumReachedCount))
-				} else if outType == FrostOccurrence {
-					_, err = fout.Write(strconv.Itoa(calcResults[refID-1].FrostOccurrence))
-				} else if outType == WetHarvest {
-					_, err = fout.Write(strconv.Itoa(calcResults[refID-1].WetHarvest))
-				} else {
-					_, err = fout.Write("-9999")
-				}
+			if refID >= 1 && refID <= size {
+				_, err = fout.Write(value(calcResults[refID-1]))
 				if err != nil {
 					return err
 				}
@@ -766,7 +1671,7 @@ func createGzFileWriter(name string) (*Fout, error) {
 	// create folder if not exists
 	if _, err := os.Stat(folder); os.IsNotExist(err) {
 		// folder does not exist
-		err = os.Mkdir(folder, 0755)
+		err = os.MkdirAll(folder, 0755)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,16 @@
 package main
 
-import "testing"
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
 
 func Test_generateCropFile(t *testing.T) {
 	type args struct {
@@ -75,3 +85,366 @@ func Test_generateCropFile(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculationResultRef_aggregateNoValidYears(t *testing.T) {
+	share := 50.0
+	crop := &Crop{
+		TsumMaturity: 1000,
+		Stages:       []Stage{{Name: "emergence", Tsum: 100}, {Name: "maturity", Tsum: 900}},
+		Suitability:  &Suitability{Classes: []SuitabilityClass{{Name: "suitable", Frost: &share}}},
+	}
+	result := newCalculationResultRef(crop, 1, 3)
+	for yearIdx := range result.invalidYears {
+		result.invalidYears[yearIdx] = true
+	}
+	result.aggregate(crop, 3)
+	if result.ValidYears != 0 {
+		t.Fatalf("ValidYears = %d, want 0", result.ValidYears)
+	}
+	for _, tt := range []struct {
+		name    string
+		outType outputType
+	}{
+		{"TsumAvg", TSumAvg},
+		{"Suitability", SuitabilityClassValue},
+	} {
+		if got := tt.outType.value(result); got != "-9999" {
+			t.Errorf("%s value = %s, want -9999", tt.name, got)
+		}
+	}
+	if result.Suitability != 0 {
+		t.Errorf("Suitability = %d, want 0", result.Suitability)
+	}
+}
+
+// weather source of test weather per grid code, reading a failing grid code returns an error
+type testWeatherSource struct {
+	weather map[string][]weatherDay
+	failing map[string]bool
+	reads   atomic.Int32 // number of read grid codes
+}
+
+func (s *testWeatherSource) Read(gridCode string, startYear, endYear int) ([]weatherDay, error) {
+	s.reads.Add(1)
+	if s.failing[gridCode] {
+		return nil, fmt.Errorf("read error")
+	}
+	// copy, weather days are modified by the calculation
+	return append([]weatherDay(nil), s.weather[gridCode]...), nil
+}
+
+func (s *testWeatherSource) Calendar() Calendar { return CalendarStandard }
+
+func (s *testWeatherSource) Close() error { return nil }
+
+// test weather source with 20 grid codes (g00 to g19), 2 references per grid code
+func newTestWeatherSource(failing ...string) (*testWeatherSource, map[string][]int) {
+	source := &testWeatherSource{weather: map[string][]weatherDay{}, failing: map[string]bool{}}
+	gridCodeToReferences := map[string][]int{}
+	for i := 0; i < 20; i++ {
+		gridCode := fmt.Sprintf("g%02d", i)
+		source.weather[gridCode] = testWeather(2000, 2002, func(year, doy int) weatherDay {
+			tavg := float64(i) + float64(doy%30)/3 + float64(year-2000)
+			return weatherDay{tavg: tavg, tmin: tavg - 5, tmax: tavg + 5, precip: float64(doy % 3)}
+		})
+		gridCodeToReferences[gridCode] = []int{2*i + 1, 2*i + 2}
+	}
+	for _, gridCode := range failing {
+		source.failing[gridCode] = true
+	}
+	return source, gridCodeToReferences
+}
+
+func Test_calculateAllWeatherFiles(t *testing.T) {
+	crops := []*Crop{
+		{Name: "early", TsumMaturity: 1000, Stages: []Stage{{Name: "maturity", Tsum: 1000, BaseTemp: 5}}, FrostTreashold: 2},
+		{Name: "late", TsumMaturity: 2000, Stages: []Stage{{Name: "maturity", Tsum: 2000, BaseTemp: 5}}, FrostTreashold: 2},
+	}
+	timeRanges := [][]*TimeRange{make([]*TimeRange, 2), make([]*TimeRange, 2)}
+	for cropIdx := range timeRanges {
+		for yearIdx := range timeRanges[cropIdx] {
+			timeRanges[cropIdx][yearIdx] = &TimeRange{StartDOY: make([]int, 40), EndDOY: make([]int, 40)}
+			for refIdx := 0; refIdx < 40; refIdx++ {
+				timeRanges[cropIdx][yearIdx].StartDOY[refIdx] = 60 + refIdx
+				timeRanges[cropIdx][yearIdx].EndDOY[refIdx] = 300
+			}
+		}
+	}
+	calculate := func(source WeatherSource, gridCodeToReferences map[string][]int, workers int) ([][]*CalculationResultRef, error) {
+		return calculateAllWeatherFiles(crops, timeRanges, gridCodeToReferences, nil, 40, 2001, 2002, source, workers, &OutputOptions{}, nil)
+	}
+	// results as text, NaN values are not equal
+	format := func(results [][]*CalculationResultRef) []string {
+		var lines []string
+		for _, cropResults := range results {
+			for _, result := range cropResults {
+				lines = append(lines, fmt.Sprintf("%+v", *result))
+			}
+		}
+		return lines
+	}
+
+	t.Run("same results for 1 and 4 workers", func(t *testing.T) {
+		source, gridCodeToReferences := newTestWeatherSource()
+		want, err := calculate(source, gridCodeToReferences, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := calculate(source, gridCodeToReferences, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(format(got), format(want)) {
+			t.Errorf("results with 4 workers differ from results with 1 worker")
+		}
+		for cropIdx, cropResults := range got {
+			for refIdx, result := range cropResults {
+				if result == nil || result.refId != refIdx+1 {
+					t.Fatalf("crop %d, refId %d: missing result", cropIdx, refIdx+1)
+				}
+			}
+		}
+	})
+
+	t.Run("first error stops the workers", func(t *testing.T) {
+		source, gridCodeToReferences := newTestWeatherSource("g00", "g10")
+		results, err := calculate(source, gridCodeToReferences, 1)
+		if err == nil || results != nil {
+			t.Fatalf("calculateAllWeatherFiles() error = %v, want error", err)
+		}
+		if !strings.Contains(err.Error(), "g00") {
+			t.Errorf("calculateAllWeatherFiles() error = %v, want error of grid code g00", err)
+		}
+		if reads := source.reads.Load(); reads >= 10 {
+			t.Errorf("%d grid codes read after the first error, want less than 10", reads)
+		}
+	})
+
+	t.Run("error with 4 workers", func(t *testing.T) {
+		source, gridCodeToReferences := newTestWeatherSource("g05")
+		if _, err := calculate(source, gridCodeToReferences, 4); err == nil || !strings.Contains(err.Error(), "g05") {
+			t.Errorf("calculateAllWeatherFiles() error = %v, want error of grid code g05", err)
+		}
+	})
+}
+
+// write a crop file with a single stage
+func writeTestCropFile(t *testing.T, name string) {
+	t.Helper()
+	data := "name: " + strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)) + "\ntsummaturity: 100\nstages:\n- name: maturity\n  tsum: 100\n"
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_readCrops(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"crops/soybean.yml", "crops/maize.yaml", "other/soybean.yml", "single/wheat.yml"} {
+		writeTestCropFile(t, filepath.Join(dir, name))
+	}
+	// ignored in folders
+	if err := os.WriteFile(filepath.Join(dir, "crops", "readme.txt"), []byte("crops"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "crops", "old.yml"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "empty"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+	tests := []struct {
+		name        string
+		cropFile    string
+		cropList    string
+		wantCrops   []string
+		wantFolders []string
+		wantErr     bool
+	}{
+		{"single crop file", path("single/wheat.yml"), "", []string{"wheat"}, []string{"out"}, false},
+		{"list of crop files", "", path("single/wheat.yml") + ", " + path("crops/maize.yaml"), []string{"wheat", "maize"}, []string{"out/wheat", "out/maize"}, false},
+		{"folder", "", path("crops"), []string{"maize", "soybean"}, []string{"out/maize", "out/soybean"}, false},
+		{"folder and crop file", "", path("crops") + "," + path("single/wheat.yml"), []string{"maize", "soybean", "wheat"}, []string{"out/maize", "out/soybean", "out/wheat"}, false},
+		{"duplicate crop names", "", path("crops") + "," + path("other/soybean.yml"), nil, nil, true},
+		{"empty folder", "", path("empty"), nil, nil, true},
+		{"missing crop file", "", path("crops/rice.yml"), nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crops, outputFolders, err := readCrops(tt.cropFile, tt.cropList, "out")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readCrops() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, crop := range crops {
+				names = append(names, crop.Name)
+			}
+			if !reflect.DeepEqual(names, tt.wantCrops) {
+				t.Errorf("readCrops() crops = %v, want %v", names, tt.wantCrops)
+			}
+			if !reflect.DeepEqual(outputFolders, tt.wantFolders) {
+				t.Errorf("readCrops() output folders = %v, want %v", outputFolders, tt.wantFolders)
+			}
+		})
+	}
+}
+
+// weather of consecutive years in the standard calendar, weather values of each day from dayWeather
+func testWeather(startYear, endYear int, dayWeather func(year, doy int) weatherDay) []weatherDay {
+	var weather []weatherDay
+	for year := startYear; year <= endYear; year++ {
+		for doy := 1; doy <= CalendarStandard.daysInYear(year); doy++ {
+			day := dayWeather(year, doy)
+			day.year, day.doy = year, doy
+			weather = append(weather, day)
+		}
+	}
+	return weather
+}
+
+// time ranges of a single reference, with the same sowing and harvest date in all years
+func testTimeRanges(numberYears, sowingDoy, harvestDoy int) []*TimeRange {
+	timeRanges := make([]*TimeRange, numberYears)
+	for yearIdx := range timeRanges {
+		timeRanges[yearIdx] = &TimeRange{StartDOY: []int{sowingDoy}, EndDOY: []int{harvestDoy}}
+	}
+	return timeRanges
+}
+
+// crop with 10 days to maturity at 10 degC, without frost at 5 degC
+func testCrop() *Crop {
+	return &Crop{
+		Name:         "test",
+		TsumMaturity: 100,
+		Stages:       []Stage{{Name: "emergence", Tsum: 50}, {Name: "maturity", Tsum: 50}},
+	}
+}
+
+func Test_seasonDays(t *testing.T) {
+	// 1999 to 2001, 2000 is a leap year
+	weather := testWeather(1999, 2001, func(year, doy int) weatherDay {
+		return weatherDay{tavg: 1, tmin: 1, tmax: 1}
+	})
+	yearStart := yearStartIndex(weather)
+	tests := []struct {
+		name       string
+		startYear  int
+		sowingDoy  int
+		harvestDoy int
+		yearIdx    int
+		wantFirst  int
+		wantLast   int
+		wantOk     bool
+	}{
+		{"within year", 1999, 100, 250, 0, 99, 249, true},
+		{"crossing year", 1999, 280, 200, 2, 365 + 279, 365 + 366 + 199, true},
+		{"first year without previous year", 1999, 280, 200, 0, 0, 0, false},
+		{"leap year", 1999, 1, 366, 1, 365, 365 + 365, true},
+		{"harvest year without weather", 2001, 100, 250, 1, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			numberYears := 3
+			timeRanges := testTimeRanges(numberYears, tt.sowingDoy, tt.harvestDoy)
+			season, ok := seasonDays(timeRanges, 1, 0, tt.yearIdx, tt.startYear, yearStart, len(weather))
+			if ok != tt.wantOk {
+				t.Fatalf("seasonDays() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if season.first != tt.wantFirst || season.last != tt.wantLast {
+				t.Errorf("seasonDays() = %d-%d, want %d-%d", season.first, season.last, tt.wantFirst, tt.wantLast)
+			}
+			// one degree day per day of the season
+			crop := &Crop{TsumMaturity: 1000, Stages: []Stage{{Name: "maturity", Tsum: 1000}}}
+			result := newCalculationResultRef(crop, 1, numberYears)
+			calculateSeason(newSeasonSetup(crop, weather, CalendarStandard), season, result, tt.yearIdx)
+			if want := float64(tt.wantLast - tt.wantFirst + 1); result.Tsum[tt.yearIdx] != want {
+				t.Errorf("calculateSeason() Tsum = %v, want %v", result.Tsum[tt.yearIdx], want)
+			}
+		})
+	}
+}
+
+func Test_calculateReferenceFirstYearInvalid(t *testing.T) {
+	weather := testWeather(2000, 2002, func(year, doy int) weatherDay {
+		return weatherDay{tavg: 10, tmin: 5, tmax: 15}
+	})
+	// winter crop, the season of the first year starts before the weather data
+	setup := newSeasonSetup(testCrop(), weather, CalendarStandard)
+	result := calculateReference(setup, testTimeRanges(3, 280, 200), 1, 0, 2000, 3, yearStartIndex(weather))
+	if want := []bool{true, false, false}; !reflect.DeepEqual(result.invalidYears, want) {
+		t.Errorf("invalidYears = %v, want %v", result.invalidYears, want)
+	}
+	if result.ValidYears != 2 || result.TsumReachedCount != 2 {
+		t.Errorf("ValidYears = %d, TsumReachedCount = %d, want 2, 2", result.ValidYears, result.TsumReachedCount)
+	}
+}
+
+// content of a gzip compressed output file
+func readGzFile(t *testing.T, name string) string {
+	t.Helper()
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// grid to reference file with a single row, one cell per reference
+func writeTestGridToRef(t *testing.T, folder string, refIds ...int) string {
+	t.Helper()
+	content := "Column_,Row,soil_ref\n"
+	for col, refId := range refIds {
+		content += fmt.Sprintf("%d,1,%d\n", col+1, refId)
+	}
+	name := filepath.Join(folder, "grid_to_ref.csv")
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func Test_writeRows(t *testing.T) {
+	results := []*CalculationResultRef{{refId: 1, TsumReachedCount: 5}, {refId: 2, TsumReachedCount: 7}}
+	tests := []struct {
+		name   string
+		lookup [][]int
+		want   string
+	}{
+		{"all references", [][]int{{1, 2}}, "5 7 \n"},
+		{"last reference", [][]int{{2, -1}, {-1, 2}}, "7 -9999 \n-9999 7 \n"},
+		{"unknown references", [][]int{{0, 3}}, "-9999 -9999 \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "grid.asc")
+			fout, err := createGzFileWriter(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = writeRows(fout, len(tt.lookup), len(tt.lookup[0]), results, TSumReached.value, tt.lookup)
+			fout.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readGzFile(t, name+".gz"); got != tt.want {
+				t.Errorf("writeRows() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
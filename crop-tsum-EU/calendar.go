@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// calendars of weather data
+// climate models use calendars without leap days (noleap, 365_day) or with 12 months of 30 days (360_day)
+// sowing and harvest dates (DOY) of input files, sowing windows and DOY outputs are in the standard calendar,
+// they are mapped to the calendar of the weather data at the same fraction of the year
+// the solar position (daylength, radiation) is calculated for the standard DOY
+
+// calendar of weather data
+type Calendar string
+
+const (
+	CalendarStandard Calendar = "standard" // gregorian calendar with leap years
+	CalendarNoLeap   Calendar = "noleap"   // 365 days in every year
+	Calendar360Day   Calendar = "360_day"  // 12 months of 30 days
+)
+
+// cumulative days before each month, in a year without leap day
+var monthStartNoLeap = [13]int{0, 31, 59, 90, 120, 151, 181, 212, 243, 273, 304, 334, 365}
+
+// parse calendar name, including CF aliases, "" for an empty name
+func parseCalendar(name string) (Calendar, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "":
+		return "", nil
+	case "standard", "gregorian", "proleptic_gregorian":
+		return CalendarStandard, nil
+	case "noleap", "no_leap", "365_day":
+		return CalendarNoLeap, nil
+	case "360_day":
+		return Calendar360Day, nil
+	}
+	return "", fmt.Errorf("calendar %s is not supported (standard, noleap, 365_day, 360_day)", name)
+}
+
+// calendar is the standard calendar, "" is the standard calendar
+func (c Calendar) isStandard() bool {
+	return c == "" || c == CalendarStandard
+}
+
+// number of days of a year
+func (c Calendar) daysInYear(year int) int {
+	switch c {
+	case CalendarNoLeap:
+		return 365
+	case Calendar360Day:
+		return 360
+	}
+	if year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+		return 366
+	}
+	return 365
+}
+
+// DOY of a date, error if the date does not exist in the calendar
+func (c Calendar) dayOfYear(year, month, day int) (int, error) {
+	if month < 1 || month > 12 || day < 1 {
+		return 0, fmt.Errorf("date %04d-%02d-%02d: invalid date", year, month, day)
+	}
+	switch c {
+	case CalendarNoLeap:
+		if day > monthStartNoLeap[month]-monthStartNoLeap[month-1] {
+			return 0, fmt.Errorf("date %04d-%02d-%02d: not in %s calendar", year, month, day, c)
+		}
+		return monthStartNoLeap[month-1] + day, nil
+	case Calendar360Day:
+		if day > 30 {
+			return 0, fmt.Errorf("date %04d-%02d-%02d: not in %s calendar", year, month, day, c)
+		}
+		return (month-1)*30 + day, nil
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day {
+		return 0, fmt.Errorf("date %04d-%02d-%02d: not in %s calendar", year, month, day, CalendarStandard)
+	}
+	return date.YearDay(), nil
+}
+
+// date of a DOY, as yyyy-mm-dd
+func (c Calendar) dateString(year, doy int) string {
+	switch c {
+	case CalendarNoLeap:
+		month := 1
+		for month < 12 && doy > monthStartNoLeap[month] {
+			month++
+		}
+		return fmt.Sprintf("%04d-%02d-%02d", year, month, doy-monthStartNoLeap[month-1])
+	case Calendar360Day:
+		return fmt.Sprintf("%04d-%02d-%02d", year, (doy-1)/30+1, (doy-1)%30+1)
+	}
+	return time.Date(year, 1, doy, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+}
+
+// date days after a date (year, DOY), days may be negative
+func (c Calendar) addDays(year, doy, days int) (int, int) {
+	if c.isStandard() {
+		date := time.Date(year, 1, doy+days, 0, 0, 0, 0, time.UTC)
+		return date.Year(), date.YearDay()
+	}
+	length := c.daysInYear(year)
+	n := year*length + doy - 1 + days
+	year = n / length
+	if n%length < 0 {
+		year--
+	}
+	return year, n - year*length + 1
+}
+
+// number of days from the first date to the second date (year, DOY)
+func (c Calendar) daysBetween(year1, doy1, year2, doy2 int) int {
+	days := doy2 - doy1
+	for year := year1; year < year2; year++ {
+		days += c.daysInYear(year)
+	}
+	return days
+}
+
+// parse a date with a Go time layout, e.g. 2006-01-02
+// calendars other than standard accept dates like 2050-02-30 (360_day),
+// their layout may only contain 2006, 01, 02 and literal characters
+func (c Calendar) parseDate(layout, value string) (year, doy int, err error) {
+	if c.isStandard() {
+		date, err := time.Parse(layout, value)
+		if err != nil {
+			return 0, 0, err
+		}
+		return date.Year(), date.YearDay(), nil
+	}
+	fields := map[string]int{}
+	pos := 0
+	for i := 0; i < len(layout); {
+		token := ""
+		for _, t := range []string{"2006", "01", "02"} {
+			if strings.HasPrefix(layout[i:], t) {
+				token = t
+				break
+			}
+		}
+		if token == "" {
+			if pos >= len(value) || value[pos] != layout[i] {
+				return 0, 0, fmt.Errorf("date %q does not match layout %q", value, layout)
+			}
+			i++
+			pos++
+			continue
+		}
+		if pos+len(token) > len(value) {
+			return 0, 0, fmt.Errorf("date %q does not match layout %q", value, layout)
+		}
+		number, err := strconv.Atoi(value[pos : pos+len(token)])
+		if err != nil {
+			return 0, 0, fmt.Errorf("date %q does not match layout %q", value, layout)
+		}
+		fields[token] = number
+		i += len(token)
+		pos += len(token)
+	}
+	if pos != len(value) || len(fields) != 3 {
+		return 0, 0, fmt.Errorf("date %q does not match layout %q", value, layout)
+	}
+	doy, err = c.dayOfYear(fields["2006"], fields["01"], fields["02"])
+	return fields["2006"], doy, err
+}
+
+// DOY of the calendar for a DOY of the standard calendar
+func (c Calendar) fromStandardDoy(doy int) int {
+	switch c {
+	case CalendarNoLeap:
+		return min(doy, 365)
+	case Calendar360Day:
+		return min(1+int(math.Round(float64(doy-1)*360/365)), 360)
+	}
+	return doy
+}
+
+// DOY of the standard calendar for a DOY of the calendar
+func (c Calendar) toStandardDoy(doy int) int {
+	if c == Calendar360Day {
+		return 1 + int(math.Round(float64(doy-1)*365/360))
+	}
+	return doy
+}
+
+// map sowing and harvest dates of time ranges from the standard calendar to the calendar
+func (c Calendar) mapTimeRanges(timeRanges []*TimeRange) {
+	if c.isStandard() {
+		return
+	}
+	for _, timeRange := range timeRanges {
+		for i := range timeRange.StartDOY {
+			timeRange.StartDOY[i] = c.fromStandardDoy(timeRange.StartDOY[i])
+			timeRange.EndDOY[i] = c.fromStandardDoy(timeRange.EndDOY[i])
+		}
+	}
+}
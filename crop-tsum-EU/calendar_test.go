@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func Test_Calendar_parseDate(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar Calendar
+		layout   string
+		value    string
+		wantYear int
+		wantDoy  int
+		wantErr  bool
+	}{
+		{"standard", CalendarStandard, "2006-01-02", "2000-03-01", 2000, 61, false},
+		{"standard invalid", CalendarStandard, "2006-01-02", "2050-02-30", 0, 0, true},
+		{"noleap", CalendarNoLeap, "2006-01-02", "2000-03-01", 2000, 60, false},
+		{"noleap leap day", CalendarNoLeap, "2006-01-02", "2000-02-29", 0, 0, true},
+		{"360_day", Calendar360Day, "2006-01-02", "2050-02-30", 2050, 60, false},
+		{"360_day last day", Calendar360Day, "2006-01-02", "2050-12-30", 2050, 360, false},
+		{"360_day day 31", Calendar360Day, "2006-01-02", "2050-01-31", 0, 0, true},
+		{"360_day other layout", Calendar360Day, "02.01.2006", "30.02.2050", 2050, 60, false},
+		{"360_day mismatch", Calendar360Day, "2006-01-02", "2050/02/30", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			year, doy, err := tt.calendar.parseDate(tt.layout, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (year != tt.wantYear || doy != tt.wantDoy) {
+				t.Errorf("parseDate() = %d, %d, want %d, %d", year, doy, tt.wantYear, tt.wantDoy)
+			}
+		})
+	}
+}
+
+func Test_Calendar_standardDoy(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar Calendar
+		standard int
+		want     int
+	}{
+		{"standard", CalendarStandard, 366, 366},
+		{"noleap", CalendarNoLeap, 366, 365},
+		{"360_day first", Calendar360Day, 1, 1},
+		{"360_day mid", Calendar360Day, 183, 181},
+		{"360_day last", Calendar360Day, 365, 360},
+		{"360_day leap day", Calendar360Day, 366, 360},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.calendar.fromStandardDoy(tt.standard)
+			if got != tt.want {
+				t.Errorf("fromStandardDoy(%d) = %d, want %d", tt.standard, got, tt.want)
+			}
+			// mapping back differs at most by one day, except for the leap day
+			if back := tt.calendar.toStandardDoy(got); tt.standard <= 365 && (back < tt.standard-1 || back > tt.standard+1) {
+				t.Errorf("toStandardDoy(%d) = %d, want %d", got, back, tt.standard)
+			}
+		})
+	}
+}
+
+func Test_Calendar_addDays(t *testing.T) {
+	tests := []struct {
+		name     string
+		calendar Calendar
+		year     int
+		doy      int
+		days     int
+		wantYear int
+		wantDoy  int
+	}{
+		{"standard leap year", CalendarStandard, 2000, 365, 1, 2000, 366},
+		{"noleap", CalendarNoLeap, 2000, 365, 1, 2001, 1},
+		{"360_day", Calendar360Day, 2000, 359, 2, 2001, 1},
+		{"360_day backward", Calendar360Day, 2001, 1, -1, 2000, 360},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			year, doy := tt.calendar.addDays(tt.year, tt.doy, tt.days)
+			if year != tt.wantYear || doy != tt.wantDoy {
+				t.Errorf("addDays() = %d, %d, want %d, %d", year, doy, tt.wantYear, tt.wantDoy)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// frost damage per stage
+// each stage may define its own frost threshold or be insensitive to frost,
+// otherwise the frost threshold of the crop is used
+
+// frost threshold of a stage and if the stage is sensitive to frost
+func (c *Crop) frostThreshold(stageIdx int) (threshold float64, sensitive bool) {
+	stage := &c.Stages[stageIdx]
+	if stage.FrostInsensitive {
+		return 0, false
+	}
+	if stage.FrostThreshold != nil {
+		return *stage.FrostThreshold, true
+	}
+	return c.FrostTreashold, true
+}
+
+// label of a stage for output column and file names
+func stageLabel(stages []Stage, stageIdx int) string {
+	name := strings.TrimSpace(stages[stageIdx].Name)
+	if name == "" {
+		return fmt.Sprintf("stage%d", stageIdx+1)
+	}
+	return strings.ReplaceAll(name, " ", "_")
+}
+
+// frost output per stage is written for crops with more than one stage
+func (c *Crop) hasStageOutput() bool {
+	return len(c.Stages) > 1
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCrop_frostThreshold(t *testing.T) {
+	threshold := -5.0
+	crop := &Crop{
+		FrostTreashold: 0,
+		Stages: []Stage{
+			{Name: "emergence", FrostThreshold: &threshold},
+			{Name: "flowering"},
+			{Name: "maturity", FrostInsensitive: true},
+		},
+	}
+	tests := []struct {
+		name          string
+		stageIdx      int
+		wantThreshold float64
+		wantSensitive bool
+	}{
+		{"stage threshold", 0, -5, true},
+		{"crop threshold", 1, 0, true},
+		{"frost insensitive", 2, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			threshold, sensitive := crop.frostThreshold(tt.stageIdx)
+			if threshold != tt.wantThreshold || sensitive != tt.wantSensitive {
+				t.Errorf("frostThreshold() = %v, %v, want %v, %v", threshold, sensitive, tt.wantThreshold, tt.wantSensitive)
+			}
+		})
+	}
+}
+
+func Test_calculateCropPerWeatherFrost(t *testing.T) {
+	threshold := -5.0
+	crop := &Crop{
+		TsumMaturity:   1100,
+		FrostTreashold: 0,
+		Stages: []Stage{
+			{Name: "emergence", Tsum: 50, FrostThreshold: &threshold},
+			{Name: "flowering", Tsum: 50},
+			{Name: "ripening", Tsum: 1000, FrostInsensitive: true},
+		},
+	}
+	// 5 days per stage at 10 degC, sowing on DOY 1
+	// emergence DOY 1-5, flowering DOY 6-10, ripening from DOY 11
+	tests := []struct {
+		name           string
+		frostDoys      []int
+		tmin           float64
+		wantFrostDays  float64
+		wantStageFrost []int
+	}{
+		{"no frost", nil, -2, 0, []int{0, 0, 0}},
+		{"above stage threshold", []int{3}, -2, 0, []int{0, 0, 0}},
+		{"below stage threshold", []int{3}, -6, 1, []int{1, 0, 0}},
+		{"crop threshold", []int{8, 9}, -2, 2, []int{0, 1, 0}},
+		{"frost insensitive stage", []int{15}, -6, 0, []int{0, 0, 0}},
+		{"several stages", []int{3, 8, 15}, -6, 2, []int{1, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weather := testWeather(2001, 2001, func(year, doy int) weatherDay {
+				day := weatherDay{tavg: 10, tmin: 5, tmax: 15}
+				for _, frostDoy := range tt.frostDoys {
+					if doy == frostDoy {
+						day.tmin = tt.tmin
+					}
+				}
+				return day
+			})
+			result := calculateCropPerWeather(crop, testTimeRanges(1, 1, 200), []int{1}, nil, 2001, 2001, weather, CalendarStandard, &OutputOptions{})[0]
+			if result.frostDays[0] != tt.wantFrostDays {
+				t.Errorf("frost days = %v, want %v", result.frostDays[0], tt.wantFrostDays)
+			}
+			if !reflect.DeepEqual(result.StageFrostOccurrence, tt.wantStageFrost) {
+				t.Errorf("StageFrostOccurrence = %v, want %v", result.StageFrostOccurrence, tt.wantStageFrost)
+			}
+			wantOccurrence := 0
+			if tt.wantFrostDays > 0 {
+				wantOccurrence = 1
+			}
+			if result.FrostOccurrence != wantOccurrence {
+				t.Errorf("FrostOccurrence = %v, want %v", result.FrostOccurrence, wantOccurrence)
+			}
+		})
+	}
+}
@@ -1,46 +1,99 @@
 package main
 
+import "fmt"
+
 // calculate harvest rain
-// from SoybeanEU project
+// default rule from SoybeanEU project:
+// the harvest is wet, if within the 10 days after maturity there are at least 5 wet days
+// (precipitation > 0) and no two dry days in a row (starting with the day of maturity)
 
-// ring buffer for last days
-type dataLastDays struct {
-	arr        []float64
-	index      int
-	currentLen int
-	capacity   int
+// wet harvest rule of a crop, unset parameters are set to the default rule
+type HarvestRainRule struct {
+	WindowDays   *int     `yaml:"windowdays,omitempty"`   // number of days evaluated (default 10)
+	Offset       *int     `yaml:"offset,omitempty"`       // evaluation window ends this many days after maturity (default 10)
+	WetDayPrecip *float64 `yaml:"wetdayprecip,omitempty"` // precipitation (mm) above which a day is wet (default 0)
+	MinWetDays   *int     `yaml:"minwetdays,omitempty"`   // minimum number of wet days in the window for a wet harvest (default 5)
+	DrySpellDays *int     `yaml:"dryspelldays,omitempty"` // number of consecutive dry days, ending within the window, that allow a dry harvest (default 2)
 }
 
-func newDataLastDays(days int) dataLastDays {
-	return dataLastDays{arr: make([]float64, days), index: 0, capacity: days}
+// default wet harvest rule from SoybeanEU project
+func defaultHarvestRainRule() *HarvestRainRule {
+	windowDays, offset, wetDayPrecip, minWetDays, drySpellDays := 10, 10, 0.0, 5, 2
+	return &HarvestRainRule{
+		WindowDays:   &windowDays,
+		Offset:       &offset,
+		WetDayPrecip: &wetDayPrecip,
+		MinWetDays:   &minWetDays,
+		DrySpellDays: &drySpellDays,
+	}
 }
 
-func (d *dataLastDays) addDay(val float64) {
-	if d.index < d.capacity-1 {
-		d.index++
-		if d.currentLen < d.capacity {
-			d.currentLen++
-		}
-	} else {
-		d.index = 0
+// set defaults for unset parameters and validate
+func (h *HarvestRainRule) validate() error {
+	defaults := defaultHarvestRainRule()
+	if h.WindowDays == nil {
+		h.WindowDays = defaults.WindowDays
+	}
+	if h.Offset == nil {
+		h.Offset = defaults.Offset
+	}
+	if h.WetDayPrecip == nil {
+		h.WetDayPrecip = defaults.WetDayPrecip
+	}
+	if h.MinWetDays == nil {
+		h.MinWetDays = defaults.MinWetDays
+	}
+	if h.DrySpellDays == nil {
+		h.DrySpellDays = defaults.DrySpellDays
+	}
+	if *h.WindowDays < 1 {
+		return fmt.Errorf("harvest rain: windowdays %d must be positive", *h.WindowDays)
+	}
+	if *h.DrySpellDays < 1 {
+		return fmt.Errorf("harvest rain: dryspelldays %d must be positive", *h.DrySpellDays)
 	}
-	d.arr[d.index] = val
+	if *h.Offset < 0 || *h.MinWetDays < 0 || *h.WetDayPrecip < 0 {
+		return fmt.Errorf("harvest rain: offset, minwetdays and wetdayprecip must not be negative")
+	}
+	if *h.MinWetDays > *h.WindowDays {
+		return fmt.Errorf("harvest rain: minwetdays %d must not exceed windowdays %d", *h.MinWetDays, *h.WindowDays)
+	}
+	return nil
+}
+
+// wet harvest rule of the crop, default rule if not set
+func (c *Crop) harvestRainRule() *HarvestRainRule {
+	if c.HarvestRain == nil {
+		return defaultHarvestRainRule()
+	}
+	return c.HarvestRain
 }
 
-func (d *dataLastDays) getData() []float64 {
-	if d.currentLen == 0 {
-		return nil
-	}
-	// return an array, starting with the oldest entry
-	rArr := make([]float64, d.currentLen)
-	hIndex := d.index
-	for i := 0; i < d.currentLen; i++ {
-		if hIndex < d.currentLen-1 {
-			hIndex++
-		} else {
-			hIndex = 0
+// check wet harvest after maturity (weather index maturityIdx)
+// returns false, if the weather data ends before the evaluation window
+func (h *HarvestRainRule) isWetHarvest(weather []weatherDay, maturityIdx int) bool {
+	last := maturityIdx + *h.Offset
+	first := last - *h.WindowDays + 1
+	if last >= len(weather) || first-*h.DrySpellDays+1 < 0 {
+		return false
+	}
+	wetDayCounter := 0
+	for dayIdx := first; dayIdx <= last; dayIdx++ {
+		if weather[dayIdx].precip > *h.WetDayPrecip {
+			wetDayCounter++
+			continue
+		}
+		// dry spell ending at this day
+		drySpell := true
+		for prevIdx := dayIdx - *h.DrySpellDays + 1; prevIdx < dayIdx; prevIdx++ {
+			if weather[prevIdx].precip > *h.WetDayPrecip {
+				drySpell = false
+				break
+			}
+		}
+		if drySpell {
+			return false
 		}
-		rArr[i] = d.arr[hIndex]
 	}
-	return rArr
+	return wetDayCounter >= *h.MinWetDays
 }
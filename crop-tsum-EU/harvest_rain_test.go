@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// wet harvest rule of the SoybeanEU project: the 15 days up to 10 days after maturity are kept,
+// the last 10 days are evaluated for wet days and two dry days in a row
+func soybeanEUWetHarvest(precip []float64, maturityIdx int) bool {
+	rainData := precip[maturityIdx-4 : maturityIdx+11]
+	wetDayCounter := 0
+	twoDryDaysInRowDry := false
+	for i, x := range rainData {
+		if i > 4 && x > 0 {
+			wetDayCounter++
+		}
+		if i > 4 && x == 0 && rainData[i-1] == 0 {
+			twoDryDaysInRowDry = true
+		}
+	}
+	return wetDayCounter >= 5 && !twoDryDaysInRowDry
+}
+
+func TestHarvestRainRule_isWetHarvestDefault(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	weather := make([]weatherDay, 5000)
+	precip := make([]float64, len(weather))
+	for i := range weather {
+		// mostly wet days, to get wet and dry harvests
+		if random.Float64() < 0.8 {
+			precip[i] = random.Float64() * 10
+		}
+		weather[i].precip = precip[i]
+	}
+	rule := defaultHarvestRainRule()
+	wet := 0
+	for maturityIdx := 4; maturityIdx+10 < len(weather); maturityIdx++ {
+		want := soybeanEUWetHarvest(precip, maturityIdx)
+		if got := rule.isWetHarvest(weather, maturityIdx); got != want {
+			t.Fatalf("isWetHarvest(%d) = %v, want %v", maturityIdx, got, want)
+		}
+		if want {
+			wet++
+		}
+	}
+	if wet == 0 {
+		t.Errorf("no wet harvest in test data")
+	}
+}
+
+func TestHarvestRainRule_isWetHarvest(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	floatPtr := func(v float64) *float64 { return &v }
+	// maturity on 28 Dec 2000, wet days from 29 Dec 2000 to 6 Jan 2001, default window up to 7 Jan 2001
+	weather := testWeather(2000, 2001, func(year, doy int) weatherDay {
+		day := weatherDay{precip: 0}
+		if (year == 2000 && doy >= 364) || (year == 2001 && doy <= 6) {
+			day.precip = 2
+		}
+		return day
+	})
+	maturityIdx := 362
+	tests := []struct {
+		name string
+		rule HarvestRainRule
+		want bool
+	}{
+		{"default across year end", HarvestRainRule{}, true},
+		{"window ends on last wet day", HarvestRainRule{WindowDays: intPtr(8), Offset: intPtr(9)}, true},
+		{"offset 0, window before maturity", HarvestRainRule{Offset: intPtr(0)}, false},
+		{"wet day precipitation above rain", HarvestRainRule{WetDayPrecip: floatPtr(2)}, false},
+		{"more wet days required", HarvestRainRule{MinWetDays: intPtr(10)}, false},
+		{"dry spell after rain", HarvestRainRule{Offset: intPtr(12)}, false},
+		{"longer dry spell required", HarvestRainRule{Offset: intPtr(12), DrySpellDays: intPtr(4)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			if err := rule.validate(); err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+			if got := rule.isWetHarvest(weather, maturityIdx); got != tt.want {
+				t.Errorf("isWetHarvest() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHarvestRainRule_validate(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	tests := []struct {
+		name       string
+		rule       HarvestRainRule
+		wantOffset int
+		wantErr    bool
+	}{
+		{"defaults", HarvestRainRule{}, 10, false},
+		{"offset 0", HarvestRainRule{Offset: intPtr(0)}, 0, false},
+		{"negative offset", HarvestRainRule{Offset: intPtr(-1)}, 0, true},
+		{"window 0", HarvestRainRule{WindowDays: intPtr(0)}, 0, true},
+		{"dry spell 0", HarvestRainRule{DrySpellDays: intPtr(0)}, 0, true},
+		{"too many wet days", HarvestRainRule{WindowDays: intPtr(4)}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := tt.rule
+			err := rule.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && *rule.Offset != tt.wantOffset {
+				t.Errorf("Offset = %d, want %d", *rule.Offset, tt.wantOffset)
+			}
+		})
+	}
+}
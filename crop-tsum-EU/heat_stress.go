@@ -0,0 +1,42 @@
+package main
+
+import "fmt"
+
+// heat stress at flowering
+// days with maximum temperature above the crop threshold are counted
+// while the crop is in one of the stages of the heat stress window (e.g. flowering, anthesis)
+
+// heat stress parameters of a crop
+type HeatStress struct {
+	Threshold float64  // maximum temperature above which heat stress occurs
+	Stages    []string // names of the stages of the heat stress window, empty for the whole season
+}
+
+// for each stage, if it is in the heat stress window
+func (h *HeatStress) stageWindow(stages []Stage) ([]bool, error) {
+	window := make([]bool, len(stages))
+	if len(h.Stages) == 0 {
+		for idx := range window {
+			window[idx] = true
+		}
+		return window, nil
+	}
+	for _, name := range h.Stages {
+		found := false
+		for idx, stage := range stages {
+			if stage.Name == name {
+				window[idx] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("heat stress: unknown stage %q", name)
+		}
+	}
+	return window, nil
+}
+
+// crop requires the maximum temperature from the weather file
+func (c *Crop) requiresTmax() bool {
+	return usesMinMaxTemp(c.ThermalTimeMethod) || c.HeatStress != nil || c.Drought != nil
+}
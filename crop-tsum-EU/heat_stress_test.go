@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeatStress_stageWindow(t *testing.T) {
+	stages := []Stage{{Name: "emergence"}, {Name: "flowering"}, {Name: "ripening"}}
+	tests := []struct {
+		name       string
+		heatStress HeatStress
+		want       []bool
+		wantErr    bool
+	}{
+		{"whole season", HeatStress{Threshold: 30}, []bool{true, true, true}, false},
+		{"flowering", HeatStress{Threshold: 30, Stages: []string{"flowering"}}, []bool{false, true, false}, false},
+		{"unknown stage", HeatStress{Threshold: 30, Stages: []string{"anthesis"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.heatStress.stageWindow(stages)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("stageWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stageWindow() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_calculateCropPerWeatherHeatStress(t *testing.T) {
+	// 5 days per stage at 10 degC, sowing on DOY 1, maturity on DOY 110
+	// emergence DOY 1-5, flowering DOY 6-10, ripening from DOY 11
+	// 35 degC on DOY 3 (emergence), 7 and 8 (flowering), 15 (ripening) and 150 (after maturity)
+	weather := testWeather(2001, 2001, func(year, doy int) weatherDay {
+		day := weatherDay{tavg: 10, tmin: 5, tmax: 15}
+		switch doy {
+		case 3, 7, 8, 15, 150:
+			day.tmax = 35
+		}
+		return day
+	})
+	tests := []struct {
+		name         string
+		heatStress   HeatStress
+		wantHeatDays float64
+	}{
+		{"flowering", HeatStress{Threshold: 30, Stages: []string{"flowering"}}, 2},
+		{"ripening", HeatStress{Threshold: 30, Stages: []string{"ripening"}}, 1},
+		{"whole season until maturity", HeatStress{Threshold: 30}, 4},
+		{"threshold not exceeded", HeatStress{Threshold: 35}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crop := &Crop{
+				TsumMaturity: 1100,
+				Stages:       []Stage{{Name: "emergence", Tsum: 50}, {Name: "flowering", Tsum: 50}, {Name: "ripening", Tsum: 1000}},
+				HeatStress:   &tt.heatStress,
+			}
+			result := calculateCropPerWeather(crop, testTimeRanges(1, 1, 200), []int{1}, nil, 2001, 2001, weather, CalendarStandard, &OutputOptions{})[0]
+			if result.heatDays[0] != tt.wantHeatDays {
+				t.Errorf("heat days = %v, want %v", result.heatDays[0], tt.wantHeatDays)
+			}
+			wantYears := 0
+			if tt.wantHeatDays > 0 {
+				wantYears = 1
+			}
+			if result.HeatStress != wantYears || result.HeatStressYears[0] != (wantYears == 1) {
+				t.Errorf("HeatStress = %v, %v, want %v", result.HeatStress, result.HeatStressYears, wantYears)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// run manifest, declares all scenarios and crops of a run
+// the full matrix of scenarios x crops is calculated with one command
+// each weather file is read once per scenario for all crops
+// results are written to <output>/<crop file name>/<scenario output>
+type RunManifest struct {
+	Reference      string     // reference file climate sowing date mapping
+	GridToRef      string     // grid to reference mapping file
+	Output         string     // output root folder
+	Workers        int        // number of weather files processed in parallel (0 = number of CPUs)
+	StartYear      int        // default start year for scenarios
+	EndYear        int        // default end year for scenarios
+	SowingDefault  int        // default sowing date (DOY), if not in sowing file
+	HarvestDefault int        // default harvest date (DOY), if not in harvest file
+	Crops          []string   // crop files or folders with crop files
+	Scenarios      []Scenario // climate scenarios
+
+	Outputs OutputOptions `yaml:"outputs,omitempty"` // optional outputs
+}
+
+// climate scenario
+type Scenario struct {
+	Name           string      // scenario name
+	Weather        string      // weather file template, %s is replaced by the weather grid code, or NetCDF file(s) (*.nc)
+	Sowing         string      // sowing dates file name
+	Harvest        string      `yaml:"harvest,omitempty"`        // harvest dates file name (optional)
+	StartYear      int         `yaml:"startyear,omitempty"`      // start year (optional, default from manifest)
+	EndYear        int         `yaml:"endyear,omitempty"`        // end year (optional, default from manifest)
+	SowingDefault  int         `yaml:"sowingdefault,omitempty"`  // default sowing date (DOY) (optional, default from manifest)
+	HarvestDefault int         `yaml:"harvestdefault,omitempty"` // default harvest date (DOY) (optional, default from manifest)
+	Output         string      `yaml:"output,omitempty"`         // output sub folder (optional, default scenario name)
+	SowingRule     *SowingRule `yaml:"sowingrule,omitempty"`     // derive sowing dates from weather (optional, replaces sowing file)
+
+	WeatherFormat *WeatherFormat `yaml:"weatherformat,omitempty"` // format of weather csv files (optional, default SoybeanEU format)
+	Missing       *MissingPolicy `yaml:"missing,omitempty"`       // policies to fill missing weather values (optional, default missing values are an error)
+	Calendar      Calendar       `yaml:"calendar,omitempty"`      // calendar of the weather data: standard, noleap, 360_day (optional, default standard or from NetCDF files)
+}
+
+// read run manifest from yml file
+// environment variables like ${CLIMATE} are expanded before parsing
+func readRunManifest(filename string) (*RunManifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &RunManifest{
+		Reference:      "stu_eu_layer_ref.csv",
+		GridToRef:      "stu_eu_layer_grid.csv",
+		Output:         "./output",
+		StartYear:      1981,
+		EndYear:        2010,
+		SowingDefault:  150,
+		HarvestDefault: 300,
+	}
+	err = yaml.Unmarshal([]byte(os.ExpandEnv(string(data))), manifest)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Outputs.OptimizeSowing != nil {
+		if err := manifest.Outputs.OptimizeSowing.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	if manifest.Outputs.LatestSowing != nil {
+		if err := manifest.Outputs.LatestSowing.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	if err := validateQuantiles(manifest.Outputs.TsumQuantiles); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(manifest.Scenarios) == 0 {
+		return nil, fmt.Errorf("%s: no scenarios defined", filename)
+	}
+	usedOutputs := make(map[string]string)
+	for i := range manifest.Scenarios {
+		scenario := &manifest.Scenarios[i]
+		if scenario.Name == "" {
+			return nil, fmt.Errorf("%s: scenario %d has no name", filename, i+1)
+		}
+		if scenario.Weather == "" {
+			return nil, fmt.Errorf("%s: scenario %s has no weather file template", filename, scenario.Name)
+		}
+		if scenario.StartYear == 0 {
+			scenario.StartYear = manifest.StartYear
+		}
+		if scenario.EndYear == 0 {
+			scenario.EndYear = manifest.EndYear
+		}
+		if scenario.StartYear > scenario.EndYear {
+			return nil, fmt.Errorf("%s: scenario %s start year %d is after end year %d", filename, scenario.Name, scenario.StartYear, scenario.EndYear)
+		}
+		if scenario.Output == "" {
+			scenario.Output = scenario.Name
+		}
+		if other, ok := usedOutputs[scenario.Output]; ok {
+			return nil, fmt.Errorf("%s: scenarios %s and %s would write to the same output folder", filename, other, scenario.Name)
+		}
+		usedOutputs[scenario.Output] = scenario.Name
+		if scenario.SowingDefault == 0 {
+			scenario.SowingDefault = manifest.SowingDefault
+		}
+		if scenario.HarvestDefault == 0 {
+			scenario.HarvestDefault = manifest.HarvestDefault
+		}
+		if scenario.WeatherFormat != nil {
+			if err := scenario.WeatherFormat.validate(); err != nil {
+				return nil, fmt.Errorf("%s: scenario %s: %w", filename, scenario.Name, err)
+			}
+		}
+		if scenario.SowingRule != nil {
+			if err := scenario.SowingRule.validate(); err != nil {
+				return nil, fmt.Errorf("%s: scenario %s: %w", filename, scenario.Name, err)
+			}
+		}
+		if scenario.Calendar, err = parseCalendar(string(scenario.Calendar)); err != nil {
+			return nil, fmt.Errorf("%s: scenario %s: %w", filename, scenario.Name, err)
+		}
+		if scenario.Missing != nil {
+			if err := scenario.Missing.validate(); err != nil {
+				return nil, fmt.Errorf("%s: scenario %s: %w", filename, scenario.Name, err)
+			}
+		}
+		// check input files early, instead of failing after the first scenarios have been calculated
+		for _, inputFile := range []string{scenario.Sowing, scenario.Harvest} {
+			if inputFile == "" {
+				continue
+			}
+			if _, err := os.Stat(inputFile); err != nil {
+				return nil, fmt.Errorf("%s: scenario %s: %w", filename, scenario.Name, err)
+			}
+		}
+	}
+	return manifest, nil
+}
+
+// run all scenarios of a manifest, for all crops
+// cropList overrides the crops of the manifest, if not empty
+func runManifest(manifest *RunManifest, cropList string, workers int) error {
+	if cropList == "" {
+		cropList = strings.Join(manifest.Crops, ",")
+	}
+	if cropList == "" {
+		return fmt.Errorf("no crops defined in manifest")
+	}
+	crops, cropOutputFolders, err := readCrops("", cropList, manifest.Output)
+	if err != nil {
+		return err
+	}
+	if manifest.Workers > 0 {
+		workers = manifest.Workers
+	}
+
+	// read reference data from csv file
+	referenceToGridCode, gridCodeToReferences, err := readClimateRefData(manifest.Reference)
+	if err != nil {
+		return err
+	}
+
+	// read latitude per reference, if required by a crop
+	latitudes, err := readLatitudesForCrops(crops, manifest.Reference, manifest.GridToRef, len(referenceToGridCode))
+	if err != nil {
+		return err
+	}
+
+	for i := range manifest.Scenarios {
+		scenario := &manifest.Scenarios[i]
+		log.Printf("scenario %s (%d-%d), %d crops", scenario.Name, scenario.StartYear, scenario.EndYear, len(crops))
+		outputFolders := make([]string, len(crops))
+		for cropIdx := range crops {
+			outputFolders[cropIdx] = filepath.Join(cropOutputFolders[cropIdx], scenario.Output)
+		}
+		err = runScenario(crops, outputFolders, scenario, referenceToGridCode, gridCodeToReferences, latitudes, manifest.GridToRef, workers, &manifest.Outputs)
+		if err != nil {
+			return fmt.Errorf("scenario %s: %w", scenario.Name, err)
+		}
+	}
+	return nil
+}
+
+// generate an example run manifest, with the scenarios of the soybeanEU project
+func generateManifestFile(manifestFileName string) error {
+	manifest := RunManifest{
+		Reference:      "${GRID_REF}/stu_eu_layer_ref.csv",
+		GridToRef:      "${GRID_REF}/stu_eu_layer_grid.csv",
+		Output:         "crops",
+		StartYear:      1981,
+		EndYear:        2010,
+		SowingDefault:  150,
+		HarvestDefault: 300,
+		Crops:          []string{"crops/soybean.yml"},
+		Scenarios: []Scenario{
+			{
+				Name:    "historical",
+				Weather: "${CLIMATE}/0/0_0/%s_v3.csv",
+				Sowing:  "sowing_dates/0_0_sowing-dates.csv.gz",
+			},
+		},
+	}
+	for _, gcm := range []string{"GFDL-CM3", "GISS-E2-R", "HadGEM2-ES", "MIROC5", "MPI-ESM-MR"} {
+		for _, rcp := range []string{"45", "85"} {
+			name := gcm + "_" + rcp
+			manifest.Scenarios = append(manifest.Scenarios, Scenario{
+				Name:    name,
+				Weather: "${CLIMATE}/2/" + name + "/%s_v3.csv",
+				Sowing:  "sowing_dates/" + name + "_sowing-dates.csv.gz",
+				Output:  "2_" + name,
+			})
+		}
+	}
+
+	data, err := yaml.Marshal(&manifest)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(manifestFileName, data, 0644)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_readRunManifest(t *testing.T) {
+	dir := t.TempDir()
+	sowingFile := filepath.Join(dir, "sowing.csv")
+	if err := os.WriteFile(sowingFile, []byte("refId,DOY,Date\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TEST_SOWING", sowingFile)
+	tests := []struct {
+		name     string
+		manifest string
+		want     Scenario
+		wantErr  bool
+	}{
+		{"manifest defaults", `
+scenarios:
+- name: historical
+  weather: weather/%s.csv
+  sowing: ${TEST_SOWING}
+`, Scenario{Name: "historical", Weather: "weather/%s.csv", Sowing: sowingFile, StartYear: 1981, EndYear: 2010,
+			SowingDefault: 150, HarvestDefault: 300, Output: "historical"}, false},
+		{"scenario overrides", `
+startyear: 1991
+endyear: 2000
+scenarios:
+- name: future
+  weather: weather/%s.csv
+  startyear: 2041
+  endyear: 2070
+  harvestdefault: 280
+  output: 2_future
+  calendar: 365_day
+`, Scenario{Name: "future", Weather: "weather/%s.csv", StartYear: 2041, EndYear: 2070,
+			SowingDefault: 150, HarvestDefault: 280, Output: "2_future", Calendar: CalendarNoLeap}, false},
+		{"no scenarios", "reference: ref.csv\n", Scenario{}, true},
+		{"missing sowing file", `
+scenarios:
+- name: historical
+  weather: weather/%s.csv
+  sowing: missing.csv
+`, Scenario{}, true},
+		{"start after end", `
+scenarios:
+- name: historical
+  weather: weather/%s.csv
+  startyear: 2010
+  endyear: 1981
+`, Scenario{}, true},
+		{"same output folder", `
+scenarios:
+- name: a
+  weather: weather/%s.csv
+  output: out
+- name: b
+  weather: weather/%s.csv
+  output: out
+`, Scenario{}, true},
+		{"no weather", `
+scenarios:
+- name: historical
+`, Scenario{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestFile := filepath.Join(dir, "manifest.yml")
+			if err := os.WriteFile(manifestFile, []byte(tt.manifest), 0644); err != nil {
+				t.Fatal(err)
+			}
+			manifest, err := readRunManifest(manifestFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readRunManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if manifest.Reference != "stu_eu_layer_ref.csv" || manifest.GridToRef != "stu_eu_layer_grid.csv" || manifest.Output != "./output" {
+				t.Errorf("readRunManifest() files = %s, %s, %s, want defaults", manifest.Reference, manifest.GridToRef, manifest.Output)
+			}
+			if got := manifest.Scenarios[0]; got != tt.want {
+				t.Errorf("readRunManifest() scenario = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+// average and inter-annual standard deviation of the maturity date,
+// and average days from maturity to the latest harvest date, over the years with maturity
+// values are NaN, if maturity is never reached
+func (result *CalculationResultRef) aggregateMaturity() {
+	doys := make([]float64, 0, result.TsumReachedCount)
+	margins := make([]float64, 0, result.TsumReachedCount)
+	for yearIdx, reached := range result.TsumReached {
+		if reached {
+			doys = append(doys, float64(result.maturityDoy[yearIdx]))
+			margins = append(margins, float64(result.maturityMargin[yearIdx]))
+		}
+	}
+	result.MaturityDoyAvg = mean(doys)
+	result.MaturityDoyStd = stdDev(doys)
+	result.MaturityMarginAvg = mean(margins)
+}
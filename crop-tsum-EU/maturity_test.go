@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCalculationResultRef_aggregateMaturity(t *testing.T) {
+	// testCrop matures after 10 days at 10 degC and after 5 days at 20 degC
+	tests := []struct {
+		name       string
+		endYear    int
+		sowingDoy  int
+		harvestDoy int
+		tavg       func(year int) float64
+		wantDoys   []int
+		wantAvg    float64
+		wantStd    float64
+		wantMargin float64
+	}{
+		{"every year", 2003, 100, 200, func(year int) float64 { return 10 }, []int{109, 109, 109}, 109, 0, 91},
+		{"varying years", 2002, 100, 200, func(year int) float64 { return float64(10 * (year - 2000)) }, []int{109, 104}, 106.5, 3.5355, 93.5},
+		{"single year", 2001, 100, 200, func(year int) float64 { return 10 }, []int{109}, 109, 0, 91},
+		{"margin against harvest date", 2001, 100, 150, func(year int) float64 { return 10 }, []int{109}, 109, 0, 41},
+		{"not every year", 2002, 100, 200, func(year int) float64 { return float64(10 * (year - 2001)) }, []int{-9999, 109}, 109, 0, 91},
+		{"never reached", 2002, 100, 200, func(year int) float64 { return 0 }, []int{-9999, -9999}, math.NaN(), math.NaN(), math.NaN()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weather := testWeather(2001, tt.endYear, func(year, doy int) weatherDay {
+				return weatherDay{tavg: tt.tavg(year), tmin: 5, tmax: 15}
+			})
+			numberYears := tt.endYear - 2001 + 1
+			result := calculateCropPerWeather(testCrop(), testTimeRanges(numberYears, tt.sowingDoy, tt.harvestDoy), []int{1}, nil, 2001, tt.endYear, weather, CalendarStandard, &OutputOptions{})[0]
+			for yearIdx, wantDoy := range tt.wantDoys {
+				if reached := wantDoy != -9999; result.TsumReached[yearIdx] != reached {
+					t.Fatalf("TsumReached[%d] = %v, want %v", yearIdx, result.TsumReached[yearIdx], reached)
+				}
+				if result.TsumReached[yearIdx] && result.maturityDoy[yearIdx] != wantDoy {
+					t.Errorf("maturityDoy[%d] = %d, want %d", yearIdx, result.maturityDoy[yearIdx], wantDoy)
+				}
+			}
+			// grid values are NODATA, if maturity is never reached
+			for _, check := range []struct {
+				name string
+				got  float64
+				want float64
+			}{
+				{"MaturityDoyAvg", result.MaturityDoyAvg, tt.wantAvg},
+				{"MaturityDoyStd", result.MaturityDoyStd, tt.wantStd},
+				{"MaturityMarginAvg", result.MaturityMarginAvg, tt.wantMargin},
+			} {
+				if math.IsNaN(check.want) {
+					if !math.IsNaN(check.got) {
+						t.Errorf("%s = %v, want NaN", check.name, check.got)
+					}
+				} else if math.Abs(check.got-check.want) > 1e-4 {
+					t.Errorf("%s = %v, want %v", check.name, check.got, check.want)
+				}
+			}
+			if math.IsNaN(tt.wantAvg) && MaturityDoyAvg.value(result) != "-9999" {
+				t.Errorf("MaturityDoyAvg grid value = %s, want -9999", MaturityDoyAvg.value(result))
+			}
+		})
+	}
+}
+
+func Test_writeCalculationResultMaturity(t *testing.T) {
+	// maturity at DOY 109 in 2002, not reached in 2001
+	weather := testWeather(2001, 2002, func(year, doy int) weatherDay {
+		return weatherDay{tavg: float64(10 * (year - 2001)), tmin: 5, tmax: 15}
+	})
+	options := &OutputOptions{TsumQuantiles: defaultTsumQuantiles}
+	results := calculateCropPerWeather(testCrop(), testTimeRanges(2, 100, 200), []int{1}, nil, 2001, 2002, weather, CalendarStandard, options)
+	outputFolder := t.TempDir()
+	gridToRefFile := writeTestGridToRef(t, outputFolder, 1)
+	if err := writeCalculationResult(testCrop(), results, []string{"0_0"}, gridToRefFile, 2001, 2002, outputFolder, options); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(readGzFile(t, filepath.Join(outputFolder, "cal_res_ref_2001-2002.csv.gz"))), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("cal_res_ref lines = %d, want 3", len(lines))
+	}
+	column := -1
+	for idx, name := range strings.Split(lines[0], ",") {
+		if name == "maturity_doy" {
+			column = idx
+		}
+	}
+	if column < 0 {
+		t.Fatalf("header = %q, want maturity_doy column", lines[0])
+	}
+	for lineIdx, want := range []string{"-9999", "109"} {
+		fields := strings.Split(lines[lineIdx+1], ",")
+		if got := fields[column]; got != want {
+			t.Errorf("maturity_doy of %s = %s, want %s", fields[2], got, want)
+		}
+	}
+}
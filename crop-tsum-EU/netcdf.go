@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// reader for NetCDF classic and 64-bit offset files (CDF-1 and CDF-2)
+// only the header is read into memory, variable data is read on demand with ReadAt,
+// so that time series of single grid cells can be read from large files by several workers
+// NetCDF-4 (HDF5) and CDF-5 files are not supported
+
+// NetCDF data types
+const (
+	ncByte   = 1
+	ncChar   = 2
+	ncShort  = 3
+	ncInt    = 4
+	ncFloat  = 5
+	ncDouble = 6
+)
+
+// NetCDF header tags
+const (
+	ncTagDimension = 0x0A
+	ncTagVariable  = 0x0B
+	ncTagAttribute = 0x0C
+)
+
+// NetCDF file, with parsed header
+type ncFile struct {
+	name       string
+	file       *os.File
+	numRecs    int
+	recSize    int64 // size of one record of all record variables
+	dims       []ncDim
+	attributes map[string]ncAttribute // global attributes
+	vars       map[string]*ncVar
+}
+
+// NetCDF dimension, record dimension has length 0 in the header
+type ncDim struct {
+	name   string
+	length int
+}
+
+// NetCDF attribute, numeric values as float64, text as string
+type ncAttribute struct {
+	text   string
+	values []float64
+}
+
+// NetCDF variable
+type ncVar struct {
+	name       string
+	dimIds     []int
+	attributes map[string]ncAttribute
+	dataType   int
+	begin      int64
+	isRecord   bool
+}
+
+// open NetCDF file and read header
+func openNetCDF(name string) (*ncFile, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	nc := &ncFile{name: name, file: file}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	// header is read sequentially, a buffered section reader avoids reading the whole file
+	err = nc.readHeader(bufio.NewReader(io.NewSectionReader(file, 0, info.Size())), info.Size())
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return nc, nil
+}
+
+// close NetCDF file
+func (nc *ncFile) Close() error {
+	return nc.file.Close()
+}
+
+// header reader, big endian
+type ncHeaderReader struct {
+	r       io.Reader
+	version byte
+	err     error
+}
+
+func (h *ncHeaderReader) read(data interface{}) {
+	if h.err == nil {
+		h.err = binary.Read(h.r, binary.BigEndian, data)
+	}
+}
+
+func (h *ncHeaderReader) int32() int {
+	var v int32
+	h.read(&v)
+	return int(v)
+}
+
+func (h *ncHeaderReader) offset() int64 {
+	if h.version == 1 {
+		var v int32
+		h.read(&v)
+		return int64(v)
+	}
+	var v int64
+	h.read(&v)
+	return v
+}
+
+// read bytes padded to 4 bytes
+func (h *ncHeaderReader) bytes(n int) []byte {
+	if h.err != nil || n < 0 {
+		if h.err == nil {
+			h.err = fmt.Errorf("invalid header")
+		}
+		return nil
+	}
+	padded := (n + 3) / 4 * 4
+	data := make([]byte, padded)
+	_, h.err = io.ReadFull(h.r, data)
+	return data[:n]
+}
+
+func (h *ncHeaderReader) name() string {
+	return string(h.bytes(h.int32()))
+}
+
+// read attribute list
+func (h *ncHeaderReader) attributes() map[string]ncAttribute {
+	attributes := make(map[string]ncAttribute)
+	tag := h.int32()
+	count := h.int32()
+	if tag != ncTagAttribute && (tag != 0 || count != 0) {
+		if h.err == nil {
+			h.err = fmt.Errorf("invalid attribute list")
+		}
+		return attributes
+	}
+	for i := 0; i < count && h.err == nil; i++ {
+		name := h.name()
+		dataType := h.int32()
+		n := h.int32()
+		size, ok := ncTypeSize(dataType)
+		if !ok {
+			h.err = fmt.Errorf("attribute %s: unknown type %d", name, dataType)
+			break
+		}
+		data := h.bytes(n * size)
+		if h.err != nil {
+			break
+		}
+		if dataType == ncChar {
+			attributes[name] = ncAttribute{text: string(data)}
+			continue
+		}
+		values := make([]float64, n)
+		for j := range values {
+			values[j] = ncDecode(dataType, data[j*size:])
+		}
+		attributes[name] = ncAttribute{values: values}
+	}
+	return attributes
+}
+
+// read NetCDF header
+func (nc *ncFile) readHeader(r io.Reader, fileSize int64) error {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return err
+	}
+	if string(magic[:3]) != "CDF" || (magic[3] != 1 && magic[3] != 2) {
+		return fmt.Errorf("not a NetCDF classic or 64-bit offset file")
+	}
+	h := &ncHeaderReader{r: r, version: magic[3]}
+	var numRecs uint32
+	h.read(&numRecs)
+
+	// dimensions
+	tag := h.int32()
+	count := h.int32()
+	if tag != ncTagDimension && (tag != 0 || count != 0) {
+		return fmt.Errorf("invalid dimension list")
+	}
+	for i := 0; i < count && h.err == nil; i++ {
+		nc.dims = append(nc.dims, ncDim{name: h.name(), length: h.int32()})
+	}
+	// global attributes
+	nc.attributes = h.attributes()
+
+	// variables
+	tag = h.int32()
+	count = h.int32()
+	if tag != ncTagVariable && (tag != 0 || count != 0) {
+		return fmt.Errorf("invalid variable list")
+	}
+	nc.vars = make(map[string]*ncVar)
+	recordVars := make([]*ncVar, 0)
+	vsizes := make(map[*ncVar]int64)
+	for i := 0; i < count && h.err == nil; i++ {
+		v := &ncVar{name: h.name()}
+		numDims := h.int32()
+		for j := 0; j < numDims && h.err == nil; j++ {
+			dimId := h.int32()
+			if dimId < 0 || dimId >= len(nc.dims) {
+				return fmt.Errorf("variable %s: invalid dimension id %d", v.name, dimId)
+			}
+			v.dimIds = append(v.dimIds, dimId)
+		}
+		v.attributes = h.attributes()
+		v.dataType = h.int32()
+		vsize := h.int32()
+		v.begin = h.offset()
+		if _, ok := ncTypeSize(v.dataType); !ok && h.err == nil {
+			return fmt.Errorf("variable %s: unknown type %d", v.name, v.dataType)
+		}
+		v.isRecord = len(v.dimIds) > 0 && nc.dims[v.dimIds[0]].length == 0
+		if v.isRecord {
+			recordVars = append(recordVars, v)
+			vsizes[v] = int64(vsize)
+		}
+		nc.vars[v.name] = v
+	}
+	if h.err != nil {
+		return h.err
+	}
+
+	// size of a record, a single record variable is not padded
+	for _, v := range recordVars {
+		nc.recSize += vsizes[v]
+	}
+	if len(recordVars) == 1 {
+		nc.recSize = nc.valueSize(recordVars[0]) * int64(nc.recordLength(recordVars[0]))
+	}
+	nc.numRecs = int(numRecs)
+	if numRecs == math.MaxUint32 && len(recordVars) > 0 && nc.recSize > 0 {
+		// streaming, number of records from file size
+		nc.numRecs = int((fileSize - recordVars[0].begin) / nc.recSize)
+	}
+	return nil
+}
+
+// size of a value of a variable in bytes
+func (nc *ncFile) valueSize(v *ncVar) int64 {
+	size, _ := ncTypeSize(v.dataType)
+	return int64(size)
+}
+
+// number of values of a variable per record (all values for non-record variables)
+func (nc *ncFile) recordLength(v *ncVar) int {
+	length := 1
+	for i, dimId := range v.dimIds {
+		if i == 0 && v.isRecord {
+			continue
+		}
+		length *= nc.dims[dimId].length
+	}
+	return length
+}
+
+// length of dimension i of a variable
+func (nc *ncFile) dimLength(v *ncVar, i int) int {
+	if i == 0 && v.isRecord {
+		return nc.numRecs
+	}
+	return nc.dims[v.dimIds[i]].length
+}
+
+// size of the data types in bytes
+func ncTypeSize(dataType int) (int, bool) {
+	switch dataType {
+	case ncByte, ncChar:
+		return 1, true
+	case ncShort:
+		return 2, true
+	case ncInt, ncFloat:
+		return 4, true
+	case ncDouble:
+		return 8, true
+	}
+	return 0, false
+}
+
+// default fill value of a data type, if no _FillValue is set
+func ncDefaultFill(dataType int) float64 {
+	switch dataType {
+	case ncByte:
+		return -127
+	case ncShort:
+		return -32767
+	case ncInt:
+		return -2147483647
+	case ncFloat:
+		return float64(float32(9.9692099683868690e+36))
+	case ncDouble:
+		return 9.9692099683868690e+36
+	}
+	return math.NaN()
+}
+
+// decode a big endian value
+func ncDecode(dataType int, data []byte) float64 {
+	switch dataType {
+	case ncByte:
+		return float64(int8(data[0]))
+	case ncChar:
+		return float64(data[0])
+	case ncShort:
+		return float64(int16(binary.BigEndian.Uint16(data)))
+	case ncInt:
+		return float64(int32(binary.BigEndian.Uint32(data)))
+	case ncFloat:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case ncDouble:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return math.NaN()
+}
+
+// read all values of a 1-dimensional variable (e.g. a coordinate variable)
+func (nc *ncFile) readAll(v *ncVar) ([]float64, error) {
+	if len(v.dimIds) != 1 {
+		return nil, fmt.Errorf("%s: variable %s is not 1-dimensional", nc.name, v.name)
+	}
+	size := nc.valueSize(v)
+	data := make([]byte, size)
+	values := make([]float64, nc.dimLength(v, 0))
+	for i := range values {
+		pos := v.begin + int64(i)*size
+		if v.isRecord {
+			pos = v.begin + int64(i)*nc.recSize
+		}
+		if _, err := nc.file.ReadAt(data, pos); err != nil {
+			return nil, fmt.Errorf("%s: variable %s: %w", nc.name, v.name, err)
+		}
+		values[i] = ncDecode(v.dataType, data)
+	}
+	return values, nil
+}
+
+// read time series of a (time, y, x) variable at grid cell y, x
+// scale_factor and add_offset are applied, _FillValue and missing_value are returned as NaN
+func (nc *ncFile) readTimeSeries(v *ncVar, y, x int) ([]float64, error) {
+	if len(v.dimIds) != 3 {
+		return nil, fmt.Errorf("%s: variable %s must have 3 dimensions (time, y, x)", nc.name, v.name)
+	}
+	ny := nc.dimLength(v, 1)
+	nx := nc.dimLength(v, 2)
+	if y < 0 || y >= ny || x < 0 || x >= nx {
+		return nil, fmt.Errorf("%s: grid cell y %d, x %d outside of %d x %d grid", nc.name, y, x, ny, nx)
+	}
+	scale := v.attributeValue("scale_factor", 1)
+	offset := v.attributeValue("add_offset", 0)
+	fillValue := v.attributeValue("_FillValue", ncDefaultFill(v.dataType))
+	missingValue := v.attributeValue("missing_value", math.NaN())
+
+	n := nc.dimLength(v, 0)
+	values := make([]float64, n)
+	cell := int64(y*nx + x)
+	size := nc.valueSize(v)
+	data := make([]byte, size)
+	for t := 0; t < n; t++ {
+		var pos int64
+		if v.isRecord {
+			pos = v.begin + int64(t)*nc.recSize + cell*size
+		} else {
+			pos = v.begin + (int64(t)*int64(ny*nx)+cell)*size
+		}
+		if _, err := nc.file.ReadAt(data, pos); err != nil {
+			return nil, fmt.Errorf("%s: variable %s: %w", nc.name, v.name, err)
+		}
+		raw := ncDecode(v.dataType, data)
+		if raw == fillValue || raw == missingValue {
+			values[t] = math.NaN()
+			continue
+		}
+		values[t] = raw*scale + offset
+	}
+	return values, nil
+}
+
+// first numeric value of a variable attribute, or default value
+func (v *ncVar) attributeValue(name string, defaultValue float64) float64 {
+	if attribute, ok := v.attributes[name]; ok && len(attribute.values) > 0 {
+		return attribute.values[0]
+	}
+	return defaultValue
+}
+
+// text of a variable attribute, empty if not set
+func (v *ncVar) attributeText(name string) string {
+	return v.attributes[name].text
+}
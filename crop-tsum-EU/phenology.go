@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+)
+
+// DOY of a stage that is not reached
+const noDoy = -9999
+
+// average DOY at which each stage is reached, over the years the stage is reached
+func (result *CalculationResultRef) aggregateStageDoy() {
+	result.StageDoyAvg = make([]float64, len(result.stageDoy))
+	for stageIdx, stageDoy := range result.stageDoy {
+		sum := 0.0
+		count := 0
+		for _, doy := range stageDoy {
+			if doy != noDoy {
+				sum += float64(doy)
+				count++
+			}
+		}
+		result.StageDoyAvg[stageIdx] = math.NaN()
+		if count > 0 {
+			result.StageDoyAvg[stageIdx] = sum / float64(count)
+		}
+	}
+}
+
+// write DOY at which each stage is reached to csv file, one line per reference, year and stage
+// DOY is relative to the harvest year, noDoy if the stage is not reached
+func writeStageDoy(crop *Crop, calculationResult []*CalculationResultRef, referenceToClim []string, startYear, endYear int, outputFolder string) error {
+	csvFileName := filepath.Join(outputFolder, fmt.Sprintf("stage_doy_%d-%d.csv", startYear, endYear))
+	csvFile, err := createGzFileWriter(csvFileName)
+	if err != nil {
+		return err
+	}
+	defer csvFile.Close()
+	_, err = csvFile.Write("refId,climate,year,stage,doy\n")
+	if err != nil {
+		return err
+	}
+	for _, result := range calculationResult {
+		for yearIdx := 0; yearIdx < endYear-startYear+1; yearIdx++ {
+			for stageIdx, stageDoy := range result.stageDoy {
+				line := fmt.Sprintf("%d,%s,%d,%s,%d\n", result.refId, referenceToClim[result.refId-1], startYear+yearIdx, stageLabel(crop.Stages, stageIdx), stageDoy[yearIdx])
+				_, err = csvFile.Write(line)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCalculationResultRef_stageDoy(t *testing.T) {
+	// testCrop reaches emergence after 5 days and maturity after 10 days at 10 degC
+	weather := testWeather(2000, 2001, func(year, doy int) weatherDay {
+		return weatherDay{tavg: 10, tmin: 5, tmax: 15}
+	})
+	tests := []struct {
+		name       string
+		sowingDoy  int
+		harvestDoy int
+		wantDoy    []int
+		wantAvg    []float64
+	}{
+		{"within year", 100, 200, []int{104, 109}, []float64{104, 109}},
+		{"stage never reached", 100, 106, []int{104, noDoy}, []float64{104, math.NaN()}},
+		// emergence on DOY 364 of the leap year 2000, maturity on DOY 3 of 2001
+		{"across year boundary", 360, 200, []int{-2, 3}, []float64{-2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the first year is invalid for seasons crossing the year boundary
+			result := calculateCropPerWeather(testCrop(), testTimeRanges(2, tt.sowingDoy, tt.harvestDoy), []int{1}, nil, 2000, 2001, weather, CalendarStandard, &OutputOptions{})[0]
+			var gotDoy []int
+			for _, stageDoy := range result.stageDoy {
+				gotDoy = append(gotDoy, stageDoy[1])
+			}
+			if !reflect.DeepEqual(gotDoy, tt.wantDoy) {
+				t.Errorf("stageDoy = %v, want %v", gotDoy, tt.wantDoy)
+			}
+			for stageIdx, want := range tt.wantAvg {
+				got := result.StageDoyAvg[stageIdx]
+				if math.IsNaN(want) != math.IsNaN(got) || (!math.IsNaN(want) && got != want) {
+					t.Errorf("StageDoyAvg[%d] = %v, want %v", stageIdx, got, want)
+				}
+			}
+		})
+	}
+}
+
+func Test_writeCalculationResultStageDoy(t *testing.T) {
+	// emergence on DOY 104, maturity on DOY 109 in 2002
+	// emergence on DOY 109 in 2001, maturity not reached before harvest on DOY 115
+	weather := testWeather(2001, 2002, func(year, doy int) weatherDay {
+		return weatherDay{tavg: float64(5 * (year - 2000)), tmin: 5, tmax: 15}
+	})
+	tests := []struct {
+		name       string
+		stageGrids bool
+	}{
+		{"without stage grids", false},
+		{"with stage grids", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := &OutputOptions{TsumQuantiles: defaultTsumQuantiles, StageGrids: tt.stageGrids}
+			// same sowing and harvest date for both references
+			timeRanges := testTimeRanges(2, 100, 115)
+			for _, timeRange := range timeRanges {
+				timeRange.StartDOY = append(timeRange.StartDOY, 100)
+				timeRange.EndDOY = append(timeRange.EndDOY, 115)
+			}
+			results := calculateCropPerWeather(testCrop(), timeRanges, []int{1, 2}, nil, 2001, 2002, weather, CalendarStandard, options)
+			outputFolder := t.TempDir()
+			gridToRefFile := writeTestGridToRef(t, outputFolder, 1, 2)
+			err := writeCalculationResult(testCrop(), results, []string{"0_0", "0_1"}, gridToRefFile, 2001, 2002, outputFolder, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// one line per reference, year and stage
+			want := "refId,climate,year,stage,doy\n" +
+				"1,0_0,2001,emergence,109\n" +
+				"1,0_0,2001,maturity,-9999\n" +
+				"1,0_0,2002,emergence,104\n" +
+				"1,0_0,2002,maturity,109\n" +
+				"2,0_1,2001,emergence,109\n" +
+				"2,0_1,2001,maturity,-9999\n" +
+				"2,0_1,2002,emergence,104\n" +
+				"2,0_1,2002,maturity,109\n"
+			if got := readGzFile(t, filepath.Join(outputFolder, "stage_doy_2001-2002.csv.gz")); got != want {
+				t.Errorf("stage_doy = %q, want %q", got, want)
+			}
+			// mean DOY grids only with the stage grids option
+			for stage, wantValue := range map[string]string{"emergence": "107", "maturity": "109"} {
+				gridFile := filepath.Join(outputFolder, "StageDoy_"+stage+"_2001-2002.asc.gz")
+				if _, err := os.Stat(gridFile); os.IsNotExist(err) == tt.stageGrids {
+					t.Fatalf("%s exists = %v, want %v", filepath.Base(gridFile), !os.IsNotExist(err), tt.stageGrids)
+				}
+				if !tt.stageGrids {
+					continue
+				}
+				lines := strings.Split(readGzFile(t, gridFile), "\n")
+				for _, cell := range strings.Fields(lines[6]) {
+					if cell != wantValue {
+						t.Errorf("%s cells = %s, want %s", filepath.Base(gridFile), lines[6], wantValue)
+						break
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// photoperiod sensitive development
+// development rate of a stage is thermal time x photoperiod factor
+// the photoperiod factor is 1 at the optimal daylength and decreases linearly
+// to 1 - sensitivity at the critical daylength
+// short day plants (e.g. soybean, millet) have a critical daylength above the optimal daylength,
+// long day plants have a critical daylength below the optimal daylength
+
+// photoperiod parameters of a stage
+type Photoperiod struct {
+	CriticalDaylength float64 // daylength (hours) with the strongest delay of development
+	OptimalDaylength  float64 // daylength (hours) without delay of development
+	Sensitivity       float64 // 0 (insensitive) to 1 (no development at critical daylength)
+}
+
+// validate photoperiod parameters
+func (p *Photoperiod) validate() error {
+	if p.CriticalDaylength == p.OptimalDaylength {
+		return fmt.Errorf("photoperiod: critical daylength must differ from optimal daylength")
+	}
+	if p.Sensitivity < 0 || p.Sensitivity > 1 {
+		return fmt.Errorf("photoperiod: sensitivity %v must be in range 0 to 1", p.Sensitivity)
+	}
+	return nil
+}
+
+// photoperiod factor (0-1) for a daylength
+func (p *Photoperiod) factor(daylength float64) float64 {
+	if p == nil {
+		return 1
+	}
+	// relative distance from optimal to critical daylength
+	rel := (daylength - p.OptimalDaylength) / (p.CriticalDaylength - p.OptimalDaylength)
+	rel = math.Max(0, math.Min(1, rel))
+	return 1 - p.Sensitivity*rel
+}
+
+// crop has at least one photoperiod sensitive stage
+func (c *Crop) usesPhotoperiod() bool {
+	for _, stage := range c.Stages {
+		if stage.Photoperiod != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// astronomical daylength (hours) for latitude (degree) and day of year
+// sunrise and sunset at a sun elevation of -0.833 degree (refraction and sun radius)
+func daylength(latitude float64, doy int) float64 {
+	lat := latitude * math.Pi / 180
+	declination := solarDeclination(doy)
+	elevation := -0.833 * math.Pi / 180
+	cosHourAngle := (math.Sin(elevation) - math.Sin(lat)*math.Sin(declination)) / (math.Cos(lat) * math.Cos(declination))
+	if cosHourAngle <= -1 {
+		// polar day
+		return 24
+	}
+	if cosHourAngle >= 1 {
+		// polar night
+		return 0
+	}
+	return 24 / math.Pi * math.Acos(cosHourAngle)
+}
+
+// solar declination (radians) for day of year
+func solarDeclination(doy int) float64 {
+	return 0.409 * math.Sin(2*math.Pi*float64(doy)/365-1.39)
+}
+
+// daylength for each day of year (index = DOY, 1-366) at a latitude
+func daylengthTable(latitude float64) []float64 {
+	table := make([]float64, 367)
+	for doy := 1; doy <= 366; doy++ {
+		table[doy] = daylength(latitude, doy)
+	}
+	return table
+}
+
+// read latitude per reference, if a crop requires it
+// returns an error, if latitude is required but missing for a reference
+func readLatitudesForCrops(crops []*Crop, referenceFile, gridToRefFile string, numberRef int) ([]float64, error) {
+	required := false
+	for _, crop := range crops {
+		if crop.usesPhotoperiod() || crop.Drought != nil {
+			required = true
+		}
+	}
+	if !required {
+		return nil, nil
+	}
+	latitudes, err := readRefLatitudes(referenceFile, gridToRefFile, numberRef)
+	if err != nil {
+		return nil, err
+	}
+	if latitudes == nil {
+		return nil, fmt.Errorf("latitude required, but no lat/latitude column in %s or %s", referenceFile, gridToRefFile)
+	}
+	for i, lat := range latitudes {
+		if math.IsNaN(lat) {
+			return nil, fmt.Errorf("latitude required, but missing for refId %d", i+1)
+		}
+	}
+	return latitudes, nil
+}
+
+// read latitude per reference (index refId-1)
+// the latitude is taken from a column lat or latitude of the reference file,
+// or, if not available there, as average latitude of the grid cells of a reference in the grid file
+// returns nil, if no latitude is available
+func readRefLatitudes(referenceFile, gridToRefFile string, numberRef int) ([]float64, error) {
+	// refId is the first column of the reference file
+	latitudes, err := readLatitudeColumn(referenceFile, "", numberRef)
+	if err != nil || latitudes != nil {
+		return latitudes, err
+	}
+	return readLatitudeColumn(gridToRefFile, "soil_ref", numberRef)
+}
+
+// read latitude column from csv file, averaged per reference
+// refColumn is the name of the refId column, empty for the first column
+// returns nil, if the file has no latitude column
+func readLatitudeColumn(filename, refColumn string, numberRef int) ([]float64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+
+	idxRef := -1
+	if refColumn == "" {
+		idxRef = 0
+	}
+	idxLat := -1
+	lineNo := 0
+	if scanner.Scan() {
+		lineNo++
+		for idx, field := range strings.Split(scanner.Text(), ",") {
+			field = strings.TrimSpace(field)
+			if strings.EqualFold(field, "lat") || strings.EqualFold(field, "latitude") {
+				idxLat = idx
+			}
+			if refColumn != "" && field == refColumn {
+				idxRef = idx
+			}
+		}
+	}
+	if idxLat < 0 {
+		return nil, nil
+	}
+	if idxRef < 0 {
+		return nil, fmt.Errorf("%s: latitude column without reference column", filename)
+	}
+
+	sum := make([]float64, numberRef)
+	count := make([]int, numberRef)
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			// skip empty lines
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if idxRef >= len(fields) || idxLat >= len(fields) {
+			return nil, fmt.Errorf("%s: line %d: missing reference or latitude field", filename, lineNo)
+		}
+		refId, err := strconv.Atoi(strings.TrimSpace(fields[idxRef]))
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", filename, lineNo, err)
+		}
+		if refId < 1 || refId > numberRef {
+			continue
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(fields[idxLat]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", filename, lineNo, err)
+		}
+		sum[refId-1] += lat
+		count[refId-1]++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	latitudes := make([]float64, numberRef)
+	for i := range latitudes {
+		if count[i] == 0 {
+			latitudes[i] = math.NaN()
+			continue
+		}
+		latitudes[i] = sum[i] / float64(count[i])
+	}
+	return latitudes, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_daylength(t *testing.T) {
+	tests := []struct {
+		name     string
+		latitude float64
+		doy      int
+		want     float64
+	}{
+		{"equator", 0, 80, 12.1},
+		{"berlin summer solstice", 52.5, 172, 16.8},
+		{"berlin winter solstice", 52.5, 355, 7.6},
+		{"polar day", 75, 172, 24},
+		{"polar night", 75, 355, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := daylength(tt.latitude, tt.doy); math.Abs(got-tt.want) > 0.2 {
+				t.Errorf("daylength() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhotoperiod_factor(t *testing.T) {
+	shortDay := &Photoperiod{CriticalDaylength: 16, OptimalDaylength: 12, Sensitivity: 0.8}
+	longDay := &Photoperiod{CriticalDaylength: 8, OptimalDaylength: 14, Sensitivity: 1}
+	tests := []struct {
+		name        string
+		photoperiod *Photoperiod
+		daylength   float64
+		want        float64
+	}{
+		{"not sensitive", nil, 16, 1},
+		{"short day optimal", shortDay, 11, 1},
+		{"short day between", shortDay, 14, 0.6},
+		{"short day critical", shortDay, 17, 0.2},
+		{"long day optimal", longDay, 15, 1},
+		{"long day between", longDay, 11, 0.5},
+		{"long day critical", longDay, 7, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.photoperiod.factor(tt.daylength); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("factor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readLatitudeColumn(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name      string
+		content   string
+		refColumn string
+		want      []float64
+		wantErr   bool
+	}{
+		{"first column", "ref,lat\n1,50\n2,52\n", "", []float64{50, 52}, false},
+		{"reference column", "Column_,Row,soil_ref,latitude\n1,1,2,51\n2,1,2,53\n3,1,1,50\n", "soil_ref", []float64{50, 52}, false},
+		{"reference without latitude", "ref,lat\n2,52\n", "", []float64{nan, 52}, false},
+		{"no latitude column", "ref,climate\n1,0_0\n", "", nil, false},
+		{"blank lines", "ref,lat\n1,50\n\n2,52\n\n", "", []float64{50, 52}, false},
+		{"missing field", "ref,lat\n1,50\n2\n", "", nil, true},
+		{"invalid latitude", "ref,lat\n1,north\n", "", nil, true},
+		{"no reference column", "ref,lat\n1,50\n", "soil_ref", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "ref.csv")
+			if err := os.WriteFile(filename, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readLatitudeColumn(filename, tt.refColumn, 2)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readLatitudeColumn() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("readLatitudeColumn() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] && !(math.IsNaN(got[i]) && math.IsNaN(tt.want[i])) {
+					t.Errorf("readLatitudeColumn() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sowing date optimization
+// for each reference, candidate sowing dates within a window are calculated
+// and the sowing date with the most suitable years is selected
+// a year is suitable, if TSum reaches maturity without frost and without wet harvest
+// the latest harvest dates are taken from the time ranges
+
+// window of candidate sowing dates
+// if Latest is before Earliest, the window crosses the turn of the year
+type SowingWindow struct {
+	Earliest int // earliest sowing date (DOY)
+	Latest   int // latest sowing date (DOY)
+	Step     int `yaml:"step,omitempty"` // days between candidate sowing dates, default 1
+}
+
+// parse sowing window from command line: earliest,latest[,step]
+// returns nil for an empty string
+func parseSowingWindow(value string) (*SowingWindow, error) {
+	if value == "" {
+		return nil, nil
+	}
+	fields := strings.Split(value, ",")
+	if len(fields) < 2 || len(fields) > 3 {
+		return nil, fmt.Errorf("sowing window %q: expected earliest,latest[,step]", value)
+	}
+	numbers := make([]int, len(fields))
+	for i, field := range fields {
+		number, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("sowing window %q: %w", value, err)
+		}
+		numbers[i] = number
+	}
+	window := &SowingWindow{Earliest: numbers[0], Latest: numbers[1]}
+	if len(numbers) == 3 {
+		window.Step = numbers[2]
+	}
+	if err := window.validate(); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// validate sowing window, set default step
+func (w *SowingWindow) validate() error {
+	if w.Earliest < 1 || w.Earliest > 365 || w.Latest < 1 || w.Latest > 365 {
+		return fmt.Errorf("sowing window %d-%d: DOY must be in range 1 to 365", w.Earliest, w.Latest)
+	}
+	if w.Step == 0 {
+		w.Step = 1
+	}
+	if w.Step < 0 {
+		return fmt.Errorf("sowing window: step %d must be positive", w.Step)
+	}
+	return nil
+}
+
+// candidate sowing dates (DOY) of the window
+func (w *SowingWindow) candidates() []int {
+	latest := w.Latest
+	if latest < w.Earliest {
+		latest += 365
+	}
+	doys := make([]int, 0, (latest-w.Earliest)/w.Step+1)
+	for doy := w.Earliest; doy <= latest; doy += w.Step {
+		doys = append(doys, (doy-1)%365+1)
+	}
+	return doys
+}
+
+// candidate sowing date (DOY) of a reference is before the harvest dates of the time ranges
+// a sowing date on or after the harvest date starts the season in the previous year,
+// this is only valid for seasons crossing the turn of the year:
+// the window crosses the turn of the year, or the reference is sown after harvest in the time ranges (winter crop)
+func (w *SowingWindow) validCandidate(setup *seasonSetup, timeRanges []*TimeRange, refId, doy, numberYears int) bool {
+	if w.Latest < w.Earliest {
+		return true
+	}
+	for yearIdx := 0; yearIdx < numberYears; yearIdx++ {
+		if timeRanges[yearIdx].StartDOY[refId-1] > timeRanges[yearIdx].EndDOY[refId-1] {
+			return true
+		}
+	}
+	doy = setup.calendar.fromStandardDoy(doy)
+	for yearIdx := 0; yearIdx < numberYears; yearIdx++ {
+		if doy >= timeRanges[yearIdx].EndDOY[refId-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// calculate all candidate sowing dates of a reference and keep the one with the most suitable years
+// on equal number of suitable years, the earliest candidate is kept
+// candidates on or after the harvest date are skipped, unless the season crosses the turn of the year
+// the optimal sowing date is noDoy (without result), if no candidate is valid
+func (result *CalculationResultRef) optimizeSowing(setup *seasonSetup, window *SowingWindow, timeRanges []*TimeRange, startYear, numberYears int, yearStart map[int]int) {
+	result.OptimalSowingDoy = noDoy
+	for _, doy := range window.candidates() {
+		if !window.validCandidate(setup, timeRanges, result.refId, doy, numberYears) {
+			continue
+		}
+		candidate := calculateReference(setup, timeRanges, result.refId, doy, startYear, numberYears, yearStart)
+		if result.OptimalSowing == nil || candidate.SuitableYears > result.OptimalSowing.SuitableYears {
+			result.OptimalSowingDoy = doy
+			result.OptimalSowing = candidate
+		}
+	}
+}
+
+// write optimal sowing date and the resulting metrics to csv file, one line per reference
+func writeOptimalSowing(calculationResult []*CalculationResultRef, referenceToClim []string, startYear, endYear int, outputFolder string) error {
+	csvFileName := filepath.Join(outputFolder, fmt.Sprintf("optimal_sowing_%d-%d.csv", startYear, endYear))
+	csvFile, err := createGzFileWriter(csvFileName)
+	if err != nil {
+		return err
+	}
+	defer csvFile.Close()
+	_, err = csvFile.Write("refId,climate,sowing_doy,suitable_years,TsumAvg,Tsum_reached,frost_occurrence,wet_harvest,suitable_years_default\n")
+	if err != nil {
+		return err
+	}
+	for _, result := range calculationResult {
+		optimal := result.OptimalSowing
+		if optimal == nil {
+			optimal = &CalculationResultRef{TsumAvg: math.NaN()}
+		}
+		line := fmt.Sprintf("%d,%s,%d,%d,%f,%d,%d,%d,%d\n", result.refId, referenceToClim[result.refId-1], result.OptimalSowingDoy,
+			optimal.SuitableYears, optimal.TsumAvg, optimal.TsumReachedCount, optimal.FrostOccurrence, optimal.WetHarvest, result.SuitableYears)
+		_, err = csvFile.Write(line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// search of the latest safe sowing date
+// the sowing dates of the window are calculated backwards from the latest sowing date,
+// the first sowing date with maturity reached in at least Share of the years is the latest safe sowing date
+type LatestSowing struct {
+	SowingWindow `yaml:",inline"`
+	Share        *float64 `yaml:"share,omitempty"` // share of years with maturity reached (0-1), default 0.8
+}
+
+// parse latest sowing search from command line: share,earliest,latest[,step]
+// returns nil for an empty string
+func parseLatestSowing(value string) (*LatestSowing, error) {
+	if value == "" {
+		return nil, nil
+	}
+	share, window, found := strings.Cut(value, ",")
+	if !found {
+		return nil, fmt.Errorf("latest sowing %q: expected share,earliest,latest[,step]", value)
+	}
+	shareValue, err := strconv.ParseFloat(strings.TrimSpace(share), 64)
+	if err != nil {
+		return nil, fmt.Errorf("latest sowing %q: %w", value, err)
+	}
+	search := &LatestSowing{Share: &shareValue}
+	sowingWindow, err := parseSowingWindow(window)
+	if err != nil {
+		return nil, err
+	}
+	search.SowingWindow = *sowingWindow
+	if err := search.validate(); err != nil {
+		return nil, err
+	}
+	return search, nil
+}
+
+// validate latest sowing search, set default share, if unset
+func (s *LatestSowing) validate() error {
+	if s.Share == nil {
+		share := 0.8
+		s.Share = &share
+	}
+	if *s.Share < 0 || *s.Share > 1 {
+		return fmt.Errorf("latest sowing: share %v must be in range 0 to 1", *s.Share)
+	}
+	return s.SowingWindow.validate()
+}
+
+// search the latest sowing date of a reference, with maturity reached in the required share of years
+// candidates on or after the harvest date are skipped, unless the season crosses the turn of the year
+// the latest sowing date is noDoy, if no sowing date of the window is safe
+func (result *CalculationResultRef) searchLatestSowing(setup *seasonSetup, search *LatestSowing, timeRanges []*TimeRange, startYear, numberYears int, yearStart map[int]int) {
+	result.LatestSowingDoy = noDoy
+	candidates := search.candidates()
+	for i := len(candidates) - 1; i >= 0; i-- {
+		if !search.validCandidate(setup, timeRanges, result.refId, candidates[i], numberYears) {
+			continue
+		}
+		candidate := calculateReference(setup, timeRanges, result.refId, candidates[i], startYear, numberYears, yearStart)
+		if candidate.ValidYears > 0 && float64(candidate.TsumReachedCount) >= *search.Share*float64(candidate.ValidYears) {
+			result.LatestSowingDoy = candidates[i]
+			return
+		}
+	}
+}
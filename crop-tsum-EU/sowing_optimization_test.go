@@ -0,0 +1,134 @@
+package main
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestCalculationResultRef_searchLatestSowing(t *testing.T) {
+	weather := testWeather(2000, 2003, func(year, doy int) weatherDay {
+		return weatherDay{tavg: 10, tmin: 5, tmax: 15}
+	})
+	tests := []struct {
+		name       string
+		sowingDoy  int
+		harvestDoy int
+		window     SowingWindow
+		want       int
+	}{
+		{"within year", 100, 250, SowingWindow{Earliest: 100, Latest: 300, Step: 1}, 241},
+		{"no safe sowing date", 100, 250, SowingWindow{Earliest: 245, Latest: 300, Step: 1}, noDoy},
+		{"winter crop", 280, 200, SowingWindow{Earliest: 250, Latest: 300, Step: 1}, 300},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup := newSeasonSetup(testCrop(), weather, CalendarStandard)
+			result := &CalculationResultRef{refId: 1}
+			share := 0.8
+			search := &LatestSowing{SowingWindow: tt.window, Share: &share}
+			result.searchLatestSowing(setup, search, testTimeRanges(3, tt.sowingDoy, tt.harvestDoy), 2001, 3, yearStartIndex(weather))
+			if result.LatestSowingDoy != tt.want {
+				t.Errorf("LatestSowingDoy = %d, want %d", result.LatestSowingDoy, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculationResultRef_optimizeSowing(t *testing.T) {
+	// rain from DOY 100 to 270, every harvest before DOY 260 is wet
+	weather := testWeather(2000, 2003, func(year, doy int) weatherDay {
+		day := weatherDay{tavg: 10, tmin: 5, tmax: 15}
+		if doy >= 100 && doy <= 270 {
+			day.precip = 5
+		}
+		return day
+	})
+	tests := []struct {
+		name         string
+		sowingDoy    int
+		harvestDoy   int
+		window       SowingWindow
+		want         int
+		wantSuitable int
+	}{
+		// candidates after the harvest date would be dry harvests of the previous year
+		{"within year", 100, 250, SowingWindow{Earliest: 100, Latest: 300, Step: 1}, 100, 0},
+		{"no valid candidate", 100, 250, SowingWindow{Earliest: 260, Latest: 300, Step: 1}, noDoy, -1},
+		{"winter crop", 280, 200, SowingWindow{Earliest: 250, Latest: 300, Step: 1}, 253, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup := newSeasonSetup(testCrop(), weather, CalendarStandard)
+			result := &CalculationResultRef{refId: 1}
+			result.optimizeSowing(setup, &tt.window, testTimeRanges(3, tt.sowingDoy, tt.harvestDoy), 2001, 3, yearStartIndex(weather))
+			if result.OptimalSowingDoy != tt.want {
+				t.Errorf("OptimalSowingDoy = %d, want %d", result.OptimalSowingDoy, tt.want)
+			}
+			if tt.wantSuitable < 0 {
+				if result.OptimalSowing != nil {
+					t.Errorf("OptimalSowing = %v, want nil", result.OptimalSowing)
+				}
+				return
+			}
+			if result.OptimalSowing.SuitableYears != tt.wantSuitable {
+				t.Errorf("SuitableYears = %d, want %d", result.OptimalSowing.SuitableYears, tt.wantSuitable)
+			}
+		})
+	}
+}
+
+func TestLatestSowing_validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		yaml      string
+		wantShare float64
+		wantErr   bool
+	}{
+		{"default share", "earliest: 100\nlatest: 200\n", 0.8, false},
+		{"share", "earliest: 100\nlatest: 200\nshare: 0.5\n", 0.5, false},
+		{"zero share", "earliest: 100\nlatest: 200\nshare: 0\n", 0, false},
+		{"negative share", "earliest: 100\nlatest: 200\nshare: -0.1\n", 0, true},
+		{"share above 1", "earliest: 100\nlatest: 200\nshare: 80\n", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := &LatestSowing{}
+			if err := yaml.Unmarshal([]byte(tt.yaml), search); err != nil {
+				t.Fatal(err)
+			}
+			err := search.validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *search.Share != tt.wantShare {
+				t.Errorf("Share = %v, want %v", *search.Share, tt.wantShare)
+			}
+		})
+	}
+}
+
+func Test_parseLatestSowing(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     string
+		wantShare float64
+		wantErr   bool
+	}{
+		{"share and window", "0.9,100,200", 0.9, false},
+		{"zero share", "0,100,200,5", 0, false},
+		{"invalid share", "most,100,200", 0, true},
+		{"no window", "0.9", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search, err := parseLatestSowing(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLatestSowing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *search.Share != tt.wantShare {
+				t.Errorf("Share = %v, want %v", *search.Share, tt.wantShare)
+			}
+		})
+	}
+}
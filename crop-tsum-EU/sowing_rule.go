@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sowing dates derived from weather
+// the crop is sown on the first day within the sowing window [Earliest, Latest],
+// when the moving average of tavg over the last Days days exceeds Threshold
+// and the precipitation of the day is below MaxPrecip
+// if no day within the window fulfills the rule, the crop is sown at the latest sowing date
+// the derived sowing dates replace the sowing dates of the sowing file and
+// are written in the format of the sowing file, to be reused with -sowing
+
+// rule to derive sowing dates from weather
+type SowingRule struct {
+	Earliest  int     // earliest sowing date (DOY)
+	Latest    int     // latest sowing date (DOY)
+	Days      int     `yaml:"days,omitempty"` // days of the moving average of tavg, default 5
+	Threshold float64 // moving average of tavg must exceed this temperature
+	MaxPrecip float64 // precipitation of the sowing day must be below this limit (mm)
+}
+
+// parse sowing rule from command line: earliest,latest,days,threshold,maxprecip
+// returns nil for an empty string
+func parseSowingRule(value string) (*SowingRule, error) {
+	if value == "" {
+		return nil, nil
+	}
+	fields := strings.Split(value, ",")
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("sowing rule %q: expected earliest,latest,days,threshold,maxprecip", value)
+	}
+	numbers := make([]float64, len(fields))
+	for i, field := range fields {
+		number, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("sowing rule %q: %w", value, err)
+		}
+		numbers[i] = number
+	}
+	rule := &SowingRule{
+		Earliest:  int(numbers[0]),
+		Latest:    int(numbers[1]),
+		Days:      int(numbers[2]),
+		Threshold: numbers[3],
+		MaxPrecip: numbers[4],
+	}
+	if err := rule.validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// validate sowing rule, set default days
+func (r *SowingRule) validate() error {
+	if r.Earliest < 1 || r.Latest > 365 || r.Earliest > r.Latest {
+		return fmt.Errorf("sowing rule: window %d-%d must be within 1 to 365, earliest before latest", r.Earliest, r.Latest)
+	}
+	if r.Days == 0 {
+		r.Days = 5
+	}
+	if r.Days < 0 {
+		return fmt.Errorf("sowing rule: days %d must be positive", r.Days)
+	}
+	if r.MaxPrecip <= 0 {
+		return fmt.Errorf("sowing rule: maxprecip %v must be positive", r.MaxPrecip)
+	}
+	return nil
+}
+
+// sowing DOY (standard calendar) of a year, derived from weather
+// yearStart is the weather index of the first day of the year
+func (r *SowingRule) sowingDoy(weather []weatherDay, yearStart int, calendar Calendar) int {
+	for doy := r.Earliest; doy <= r.Latest; doy++ {
+		dayIdx := yearStart + calendar.fromStandardDoy(doy) - 1
+		if dayIdx-r.Days+1 < 0 || dayIdx >= len(weather) {
+			continue
+		}
+		sum := 0.0
+		for i := dayIdx - r.Days + 1; i <= dayIdx; i++ {
+			sum += weather[i].tavg
+		}
+		if sum/float64(r.Days) > r.Threshold && weather[dayIdx].precip < r.MaxPrecip {
+			return doy
+		}
+	}
+	return r.Latest
+}
+
+// sowing dates derived from weather, for all references
+type derivedSowing struct {
+	rule *SowingRule
+	doy  [][]int // sowing DOY, index [year-startYear][refId-1]
+}
+
+// create derived sowing dates for a scenario, nil without sowing rule
+func newDerivedSowing(rule *SowingRule, numberRef, numberYears int) *derivedSowing {
+	if rule == nil {
+		return nil
+	}
+	sowing := &derivedSowing{
+		rule: rule,
+		doy:  make([][]int, numberYears),
+	}
+	for yearIdx := range sowing.doy {
+		sowing.doy[yearIdx] = make([]int, numberRef)
+	}
+	return sowing
+}
+
+// derive sowing dates of the references of a weather file and set them in the time ranges of each crop
+// each reference belongs to one weather file, so weather files can be processed in parallel
+// sowing dates are written in the standard calendar and set in the calendar of the weather data
+func (sowing *derivedSowing) apply(crops []*Crop, timeRanges [][]*TimeRange, refIds []int, startYear int, weather []weatherDay, calendar Calendar) {
+	if sowing == nil {
+		return
+	}
+	yearStart := yearStartIndex(weather)
+	for yearIdx := range sowing.doy {
+		start, ok := yearStart[startYear+yearIdx]
+		if !ok {
+			continue
+		}
+		doy := sowing.rule.sowingDoy(weather, start, calendar)
+		for _, refId := range refIds {
+			sowing.doy[yearIdx][refId-1] = doy
+			for cropIdx, crop := range crops {
+				timeRanges[cropIdx][yearIdx].StartDOY[refId-1] = calendar.fromStandardDoy(doy + crop.SowingDateAdjustment)
+			}
+		}
+	}
+}
+
+// write derived sowing dates in the format of the sowing file: refId,DOY,Date
+func (sowing *derivedSowing) write(startYear, endYear int, outputFolder string) error {
+	csvFileName := filepath.Join(outputFolder, fmt.Sprintf("sowing_dates_%d-%d.csv", startYear, endYear))
+	csvFile, err := createGzFileWriter(csvFileName)
+	if err != nil {
+		return err
+	}
+	defer csvFile.Close()
+	_, err = csvFile.Write("refId,DOY,Date\n")
+	if err != nil {
+		return err
+	}
+	for refIdx := range sowing.doy[0] {
+		for yearIdx, doys := range sowing.doy {
+			// references without weather file have no sowing date
+			if doys[refIdx] == 0 {
+				continue
+			}
+			date := time.Date(startYear+yearIdx, 1, doys[refIdx], 0, 0, 0, 0, time.UTC)
+			_, err = csvFile.Write(fmt.Sprintf("%d,%d,%s\n", refIdx+1, doys[refIdx], date.Format("2006-01-02")))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestSowingRule_sowingDoy(t *testing.T) {
+	// tavg rises by 1 degC every 10 days, 10 degC on DOY 100, rain from DOY 103 to 105
+	weather := testWeather(2001, 2001, func(year, doy int) weatherDay {
+		day := weatherDay{tavg: float64(doy) / 10}
+		if doy >= 103 && doy <= 105 {
+			day.precip = 20
+		}
+		return day
+	})
+	tests := []struct {
+		name string
+		rule SowingRule
+		want int
+	}{
+		// moving average of DOY 99 to 103 is 10.1 degC
+		{"moving average", SowingRule{Earliest: 90, Latest: 150, Days: 5, Threshold: 10, MaxPrecip: 50}, 103},
+		{"precipitation limit", SowingRule{Earliest: 90, Latest: 150, Days: 5, Threshold: 10, MaxPrecip: 5}, 106},
+		{"earliest sowing date", SowingRule{Earliest: 120, Latest: 150, Days: 5, Threshold: 10, MaxPrecip: 5}, 120},
+		{"fallback to latest", SowingRule{Earliest: 90, Latest: 150, Days: 5, Threshold: 20, MaxPrecip: 5}, 150},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.sowingDoy(weather, 0, CalendarStandard); got != tt.want {
+				t.Errorf("sowingDoy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDerivedSowing_write(t *testing.T) {
+	rule := &SowingRule{Earliest: 90, Latest: 150, Days: 5, Threshold: 10, MaxPrecip: 5}
+	sowing := newDerivedSowing(rule, 3, 2)
+	// reference 2 has no weather file
+	sowing.doy[0] = []int{103, 0, 120}
+	sowing.doy[1] = []int{150, 0, 91}
+	outputFolder := t.TempDir()
+	if err := sowing.write(2000, 2001, outputFolder); err != nil {
+		t.Fatal(err)
+	}
+	timeRanges := make([]*TimeRange, 2)
+	for yearIdx := range timeRanges {
+		timeRanges[yearIdx] = &TimeRange{StartDOY: []int{1, 1, 1}, EndDOY: []int{300, 300, 300}}
+	}
+	sowingFile := filepath.Join(outputFolder, fmt.Sprintf("sowing_dates_%d-%d.csv.gz", 2000, 2001))
+	if err := readDOY(sowingFile, 2000, 2001, timeRanges, true, 0); err != nil {
+		t.Fatal(err)
+	}
+	for yearIdx, doys := range sowing.doy {
+		for refIdx, doy := range doys {
+			// without derived sowing date, the sowing date is not changed
+			if doy == 0 {
+				doy = 1
+			}
+			if got := timeRanges[yearIdx].StartDOY[refIdx]; got != doy {
+				t.Errorf("year %d, refId %d: sowing DOY = %d, want %d", 2000+yearIdx, refIdx+1, got, doy)
+			}
+		}
+	}
+}
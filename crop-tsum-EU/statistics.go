@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// inter-annual statistics of TSum per reference
+// quantiles are given in percent (e.g. 10, 50, 90) and calculated with linear interpolation
+// between the order statistics (type 7 of Hyndman and Fan)
+
+// default quantiles of TSum (percent)
+var defaultTsumQuantiles = []float64{10, 50, 90}
+
+// parse quantiles (percent) from command line: comma separated list, e.g. 10,50,90
+func parseQuantiles(value string) ([]float64, error) {
+	quantiles := make([]float64, 0)
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		q, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("quantiles %q: %w", value, err)
+		}
+		quantiles = append(quantiles, q)
+	}
+	return quantiles, validateQuantiles(quantiles)
+}
+
+// validate quantiles (percent)
+func validateQuantiles(quantiles []float64) error {
+	for _, q := range quantiles {
+		if q < 0 || q > 100 {
+			return fmt.Errorf("quantile %v must be in range 0 to 100", q)
+		}
+	}
+	return nil
+}
+
+// label of a quantile for output column and file names, e.g. P10
+func quantileLabel(q float64) string {
+	return "P" + strconv.FormatFloat(q, 'f', -1, 64)
+}
+
+// arithmetic mean, NaN for no values
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// sample standard deviation, 0 for a single value, NaN for no values
+func stdDev(values []float64) float64 {
+	if len(values) < 2 {
+		if len(values) == 1 {
+			return 0
+		}
+		return math.NaN()
+	}
+	m := mean(values)
+	sumSq := 0.0
+	for _, v := range values {
+		sumSq += (v - m) * (v - m)
+	}
+	return math.Sqrt(sumSq / float64(len(values)-1))
+}
+
+// quantile (percent) of sorted values, NaN for no values
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	pos := q / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (pos-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// values without missing values (NaN)
+func withoutNaN(values []float64) []float64 {
+	valid := make([]float64, 0, len(values))
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			valid = append(valid, v)
+		}
+	}
+	return valid
+}
+
+// standard deviation, coefficient of variation and quantiles of TSum over all valid years
+func (result *CalculationResultRef) tsumStatistics(quantiles []float64) {
+	tsum := withoutNaN(result.validValues(result.Tsum))
+	result.TsumStd = stdDev(tsum)
+	result.TsumCv = math.NaN()
+	if result.TsumAvg > 0 {
+		result.TsumCv = result.TsumStd / result.TsumAvg * 100
+	}
+	sorted := append([]float64(nil), tsum...)
+	sort.Float64s(sorted)
+	result.TsumQuantiles = make([]float64, len(quantiles))
+	for i, q := range quantiles {
+		result.TsumQuantiles[i] = quantile(sorted, q)
+	}
+}
+
+// write TSum statistics to csv file, one line per reference
+func writeTsumStatistics(calculationResult []*CalculationResultRef, referenceToClim []string, quantiles []float64, startYear, endYear int, outputFolder string) error {
+	csvFileName := filepath.Join(outputFolder, fmt.Sprintf("tsum_stats_%d-%d.csv", startYear, endYear))
+	csvFile, err := createGzFileWriter(csvFileName)
+	if err != nil {
+		return err
+	}
+	defer csvFile.Close()
+	header := "refId,climate,TsumAvg,Tsum_sd,Tsum_cv"
+	for _, q := range quantiles {
+		header += ",Tsum_" + quantileLabel(q)
+	}
+	header += ",Tsum_trend,Tsum_trend_p,frost_trend,frost_trend_p"
+
+	_, err = csvFile.Write(header + "\n")
+	if err != nil {
+		return err
+	}
+	for _, result := range calculationResult {
+		line := fmt.Sprintf("%d,%s,%f,%f,%f", result.refId, referenceToClim[result.refId-1], result.TsumAvg, result.TsumStd, result.TsumCv)
+		for _, value := range result.TsumQuantiles {
+			line += fmt.Sprintf(",%f", value)
+		}
+		line += fmt.Sprintf(",%f,%f,%f,%f", result.TsumTrend, result.TsumTrendP, result.FrostTrend, result.FrostTrendP)
+		_, err = csvFile.Write(line + "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_quantile(t *testing.T) {
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	tests := []struct {
+		name   string
+		sorted []float64
+		q      float64
+		want   float64
+	}{
+		{"median", sorted, 50, 6},
+		{"P10", sorted, 10, 2},
+		{"P90", sorted, 90, 10},
+		{"interpolated", []float64{10, 20}, 25, 12.5},
+		{"minimum", sorted, 0, 1},
+		{"maximum", sorted, 100, 11},
+		{"single value", []float64{7}, 90, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quantile(tt.sorted, tt.q); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("quantile() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if got := quantile(nil, 50); !math.IsNaN(got) {
+		t.Errorf("quantile() of no values = %v, want NaN", got)
+	}
+}
+
+func Test_stdDev(t *testing.T) {
+	if got := stdDev([]float64{2, 4, 4, 4, 5, 5, 7, 9}); math.Abs(got-2.138089935) > 1e-6 {
+		t.Errorf("stdDev() = %v, want 2.138", got)
+	}
+	if got := stdDev([]float64{3}); got != 0 {
+		t.Errorf("stdDev() of a single value = %v, want 0", got)
+	}
+}
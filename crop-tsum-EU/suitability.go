@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// composite crop suitability
+// classes are evaluated in the order of the crop file, the first class with all criteria fulfilled is assigned
+// criteria are given in percent of years, unset criteria are not evaluated
+// a reference without matching class is unsuitable (value 0)
+// example:
+//   suitability:
+//     classes:
+//     - name: suitable
+//       tsumreached: 80 # Tsum reached in at least 80% of years
+//       frost: 10       # frost in at most 10% of years
+//       wetharvest: 20  # wet harvest in at most 20% of years
+
+// name of references without matching class
+const unsuitableClass = "unsuitable"
+
+// suitability classification of a crop
+type Suitability struct {
+	Classes []SuitabilityClass
+}
+
+// suitability class with its criteria (percent of years)
+type SuitabilityClass struct {
+	Name        string
+	TsumReached *float64 `yaml:"tsumreached,omitempty"` // minimum share of years with TSum reached
+	Frost       *float64 `yaml:"frost,omitempty"`       // maximum share of years with frost
+	WetHarvest  *float64 `yaml:"wetharvest,omitempty"`  // maximum share of years with wet harvest
+	HeatStress  *float64 `yaml:"heatstress,omitempty"`  // maximum share of years with heat stress (crops with heat stress)
+	Drought     *float64 `yaml:"drought,omitempty"`     // maximum share of years with drought (crops with drought)
+}
+
+// validate suitability classes
+func (s *Suitability) validate(crop *Crop) error {
+	if len(s.Classes) == 0 {
+		return fmt.Errorf("suitability: no classes")
+	}
+	for _, class := range s.Classes {
+		if class.Name == "" || class.Name == unsuitableClass {
+			return fmt.Errorf("suitability: class name %q is not allowed", class.Name)
+		}
+		for _, share := range []*float64{class.TsumReached, class.Frost, class.WetHarvest, class.HeatStress, class.Drought} {
+			if share != nil && (*share < 0 || *share > 100) {
+				return fmt.Errorf("suitability: class %s: share %v must be in range 0 to 100", class.Name, *share)
+			}
+		}
+		if class.HeatStress != nil && crop.HeatStress == nil {
+			return fmt.Errorf("suitability: class %s: heat stress criterion requires heatstress parameters", class.Name)
+		}
+		if class.Drought != nil && crop.Drought == nil {
+			return fmt.Errorf("suitability: class %s: drought criterion requires drought parameters", class.Name)
+		}
+	}
+	return nil
+}
+
+// class value of a reference: index of the first matching class + 1, 0 for unsuitable
+// without years no class matches, the grid value is NODATA
+func (s *Suitability) classify(result *CalculationResultRef, numberYears int) int {
+	if numberYears <= 0 {
+		return 0
+	}
+	share := func(count int) float64 {
+		return float64(count) / float64(numberYears) * 100
+	}
+	for classIdx, class := range s.Classes {
+		if class.TsumReached != nil && share(result.TsumReachedCount) < *class.TsumReached {
+			continue
+		}
+		if class.Frost != nil && share(result.FrostOccurrence) > *class.Frost {
+			continue
+		}
+		if class.WetHarvest != nil && share(result.WetHarvest) > *class.WetHarvest {
+			continue
+		}
+		if class.HeatStress != nil && share(result.HeatStress) > *class.HeatStress {
+			continue
+		}
+		if class.Drought != nil && share(result.DroughtRisk) > *class.Drought {
+			continue
+		}
+		return classIdx + 1
+	}
+	return 0
+}
+
+// description of the criteria of a class
+func (class *SuitabilityClass) description() string {
+	criteria := make([]string, 0, 5)
+	if class.TsumReached != nil {
+		criteria = append(criteria, fmt.Sprintf("Tsum reached >= %v%%", *class.TsumReached))
+	}
+	if class.Frost != nil {
+		criteria = append(criteria, fmt.Sprintf("frost <= %v%%", *class.Frost))
+	}
+	if class.WetHarvest != nil {
+		criteria = append(criteria, fmt.Sprintf("wet harvest <= %v%%", *class.WetHarvest))
+	}
+	if class.HeatStress != nil {
+		criteria = append(criteria, fmt.Sprintf("heat stress <= %v%%", *class.HeatStress))
+	}
+	if class.Drought != nil {
+		criteria = append(criteria, fmt.Sprintf("drought <= %v%%", *class.Drought))
+	}
+	return strings.Join(criteria, " AND ")
+}
+
+// write legend of the suitability grid: value,class,criteria
+func (s *Suitability) writeLegend(outputFolder string) error {
+	legendFile, err := createGzFileWriter(filepath.Join(outputFolder, "Suitability_legend.csv"))
+	if err != nil {
+		return err
+	}
+	defer legendFile.Close()
+	_, err = legendFile.Write("value,class,criteria\n")
+	if err != nil {
+		return err
+	}
+	_, err = legendFile.Write(fmt.Sprintf("0,%s,no class matches\n", unsuitableClass))
+	if err != nil {
+		return err
+	}
+	for classIdx := range s.Classes {
+		class := &s.Classes[classIdx]
+		_, err = legendFile.Write(fmt.Sprintf("%d,%s,%s\n", classIdx+1, class.Name, class.description()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
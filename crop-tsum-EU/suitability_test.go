@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSuitability_classify(t *testing.T) {
+	share := func(v float64) *float64 { return &v }
+	suitability := &Suitability{Classes: []SuitabilityClass{
+		{Name: "suitable", TsumReached: share(80), Frost: share(10), WetHarvest: share(20)},
+		{Name: "marginal", TsumReached: share(50)},
+		{Name: "frost free", Frost: share(0)},
+	}}
+	tests := []struct {
+		name        string
+		result      CalculationResultRef
+		numberYears int
+		want        int
+	}{
+		{"all criteria", CalculationResultRef{TsumReachedCount: 9, FrostOccurrence: 1, WetHarvest: 2}, 10, 1},
+		{"first matching class", CalculationResultRef{TsumReachedCount: 10}, 10, 1},
+		{"frost excludes first class", CalculationResultRef{TsumReachedCount: 10, FrostOccurrence: 2}, 10, 2},
+		{"wet harvest excludes first class", CalculationResultRef{TsumReachedCount: 10, WetHarvest: 3}, 10, 2},
+		{"unset criteria not evaluated", CalculationResultRef{TsumReachedCount: 2, WetHarvest: 10}, 10, 3},
+		{"unsuitable", CalculationResultRef{TsumReachedCount: 2, FrostOccurrence: 1}, 10, 0},
+		{"share of valid years", CalculationResultRef{TsumReachedCount: 4, FrostOccurrence: 0}, 5, 1},
+		{"no years", CalculationResultRef{}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := suitability.classify(&tt.result, tt.numberYears); got != tt.want {
+				t.Errorf("classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuitability_validate(t *testing.T) {
+	share := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name        string
+		suitability Suitability
+		crop        Crop
+		wantErr     bool
+	}{
+		{"valid", Suitability{Classes: []SuitabilityClass{{Name: "suitable", TsumReached: share(80)}}}, Crop{}, false},
+		{"no classes", Suitability{}, Crop{}, true},
+		{"no name", Suitability{Classes: []SuitabilityClass{{TsumReached: share(80)}}}, Crop{}, true},
+		{"reserved name", Suitability{Classes: []SuitabilityClass{{Name: unsuitableClass}}}, Crop{}, true},
+		{"share out of range", Suitability{Classes: []SuitabilityClass{{Name: "suitable", Frost: share(110)}}}, Crop{}, true},
+		{"heat stress without parameters", Suitability{Classes: []SuitabilityClass{{Name: "suitable", HeatStress: share(10)}}}, Crop{}, true},
+		{"heat stress", Suitability{Classes: []SuitabilityClass{{Name: "suitable", HeatStress: share(10)}}}, Crop{HeatStress: &HeatStress{Threshold: 30}}, false},
+		{"drought without parameters", Suitability{Classes: []SuitabilityClass{{Name: "suitable", Drought: share(10)}}}, Crop{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.suitability.validate(&tt.crop); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSuitability_writeLegend(t *testing.T) {
+	share := func(v float64) *float64 { return &v }
+	suitability := &Suitability{Classes: []SuitabilityClass{
+		{Name: "suitable", TsumReached: share(80), Frost: share(10)},
+		{Name: "marginal", TsumReached: share(50)},
+	}}
+	outputFolder := t.TempDir()
+	if err := suitability.writeLegend(outputFolder); err != nil {
+		t.Fatal(err)
+	}
+	want := "value,class,criteria\n" +
+		"0,unsuitable,no class matches\n" +
+		"1,suitable,Tsum reached >= 80% AND frost <= 10%\n" +
+		"2,marginal,Tsum reached >= 50%\n"
+	if got := readGzFile(t, filepath.Join(outputFolder, "Suitability_legend.csv.gz")); got != want {
+		t.Errorf("legend = %q, want %q", got, want)
+	}
+}
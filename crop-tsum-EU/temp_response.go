@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// temperature response functions for thermal time
+// the response is given in degree days, so that all functions can be summed up to a TSum
+// - linear: temp - base temperature, clamped at zero (default)
+// - cutoff: like linear, but temperatures above TMax count as TMax (horizontal cutoff)
+// - trapezoid: linear increase from base temperature to TOpt, optimum plateau until TOptUpper,
+//   linear decrease to zero at TMax
+// - beta: Wang-Engel beta function with cardinal temperatures base temperature, TOpt and TMax,
+//   scaled to TOpt - base temperature at the optimum
+
+// response function types
+const (
+	ResponseLinear    = "linear"
+	ResponseCutoff    = "cutoff"
+	ResponseTrapezoid = "trapezoid"
+	ResponseBeta      = "beta"
+)
+
+// temperature response function for a stage
+type TempResponse struct {
+	Type      string  // linear, cutoff, trapezoid or beta
+	TOpt      float64 // optimum temperature (trapezoid: lower optimum)
+	TOptUpper float64 // upper optimum temperature (trapezoid)
+	TMax      float64 // maximum temperature, no development above (cutoff: upper threshold)
+}
+
+// validate cardinal temperatures of the response function
+func (r *TempResponse) validate(baseTemp float64) error {
+	switch r.Type {
+	case "", ResponseLinear:
+		return nil
+	case ResponseCutoff:
+		if r.TMax <= baseTemp {
+			return fmt.Errorf("cutoff response: tmax %v must be above base temperature %v", r.TMax, baseTemp)
+		}
+	case ResponseTrapezoid:
+		if !(baseTemp < r.TOpt && r.TOpt <= r.TOptUpper && r.TOptUpper < r.TMax) {
+			return fmt.Errorf("trapezoid response: requires base temperature %v < topt %v <= toptupper %v < tmax %v", baseTemp, r.TOpt, r.TOptUpper, r.TMax)
+		}
+	case ResponseBeta:
+		if !(baseTemp < r.TOpt && r.TOpt < r.TMax) {
+			return fmt.Errorf("beta response: requires base temperature %v < topt %v < tmax %v", baseTemp, r.TOpt, r.TMax)
+		}
+	default:
+		return fmt.Errorf("unknown response function type %q", r.Type)
+	}
+	return nil
+}
+
+// thermal time (degree days) of a day with the given temperature
+func (r *TempResponse) thermalTime(temp, baseTemp float64) float64 {
+	if r == nil {
+		return linearResponse(temp, baseTemp)
+	}
+	switch r.Type {
+	case ResponseCutoff:
+		return linearResponse(math.Min(temp, r.TMax), baseTemp)
+	case ResponseTrapezoid:
+		if temp <= baseTemp || temp >= r.TMax {
+			return 0
+		}
+		if temp < r.TOpt {
+			return temp - baseTemp
+		}
+		if temp <= r.TOptUpper {
+			return r.TOpt - baseTemp
+		}
+		return (r.TOpt - baseTemp) * (r.TMax - temp) / (r.TMax - r.TOptUpper)
+	case ResponseBeta:
+		if temp <= baseTemp || temp >= r.TMax {
+			return 0
+		}
+		alpha := math.Ln2 / math.Log((r.TMax-baseTemp)/(r.TOpt-baseTemp))
+		optRange := math.Pow(r.TOpt-baseTemp, alpha)
+		tempRange := math.Pow(temp-baseTemp, alpha)
+		rate := (2*tempRange*optRange - tempRange*tempRange) / (optRange * optRange)
+		return rate * (r.TOpt - baseTemp)
+	default:
+		return linearResponse(temp, baseTemp)
+	}
+}
+
+// linear response, temperature above base temperature
+func linearResponse(temp, baseTemp float64) float64 {
+	temp = temp - baseTemp
+	if temp < 0 {
+		temp = 0
+	}
+	return temp
+}
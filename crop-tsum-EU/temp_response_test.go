@@ -0,0 +1,78 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTempResponse_thermalTime(t *testing.T) {
+	type args struct {
+		temp     float64
+		baseTemp float64
+	}
+	cutoff := &TempResponse{Type: ResponseCutoff, TMax: 30}
+	trapezoid := &TempResponse{Type: ResponseTrapezoid, TOpt: 20, TOptUpper: 28, TMax: 40}
+	beta := &TempResponse{Type: ResponseBeta, TOpt: 30, TMax: 42}
+
+	tests := []struct {
+		name     string
+		response *TempResponse
+		args     args
+		want     float64
+	}{
+		{"default below base", nil, args{5, 8}, 0},
+		{"default above base", nil, args{20, 8}, 12},
+		{"linear", &TempResponse{Type: ResponseLinear}, args{35, 8}, 27},
+		{"cutoff below", cutoff, args{25, 8}, 17},
+		{"cutoff above", cutoff, args{35, 8}, 22},
+		{"trapezoid rising", trapezoid, args{15, 8}, 7},
+		{"trapezoid plateau", trapezoid, args{25, 8}, 12},
+		{"trapezoid falling", trapezoid, args{34, 8}, 6},
+		{"trapezoid above max", trapezoid, args{41, 8}, 0},
+		{"beta below base", beta, args{8, 10}, 0},
+		{"beta optimum", beta, args{30, 10}, 20},
+		{"beta above max", beta, args{43, 10}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.response.thermalTime(tt.args.temp, tt.args.baseTemp)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("thermalTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// beta function is below the optimum on both sides
+	if v := beta.thermalTime(20, 10); v <= 0 || v >= 20 {
+		t.Errorf("beta thermalTime(20) = %v, want between 0 and 20", v)
+	}
+	if v := beta.thermalTime(38, 10); v <= 0 || v >= 20 {
+		t.Errorf("beta thermalTime(38) = %v, want between 0 and 20", v)
+	}
+}
+
+func TestTempResponse_validate(t *testing.T) {
+	tests := []struct {
+		name     string
+		response TempResponse
+		baseTemp float64
+		wantErr  bool
+	}{
+		{"linear", TempResponse{Type: ResponseLinear}, 8, false},
+		{"cutoff", TempResponse{Type: ResponseCutoff, TMax: 30}, 8, false},
+		{"cutoff below base", TempResponse{Type: ResponseCutoff, TMax: 5}, 8, true},
+		{"trapezoid", TempResponse{Type: ResponseTrapezoid, TOpt: 20, TOptUpper: 28, TMax: 40}, 8, false},
+		{"trapezoid unordered", TempResponse{Type: ResponseTrapezoid, TOpt: 30, TOptUpper: 28, TMax: 40}, 8, true},
+		{"beta", TempResponse{Type: ResponseBeta, TOpt: 30, TMax: 42}, 8, false},
+		{"beta optimum at max", TempResponse{Type: ResponseBeta, TOpt: 42, TMax: 42}, 8, true},
+		{"unknown", TempResponse{Type: "quadratic"}, 8, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.response.validate(tt.baseTemp)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// methods to calculate daily thermal time
+// - tavg: daily average temperature of the weather data with the response function of the stage (default)
+// - averaging: mean of tmin and tmax with the response function of the stage
+// - single_sine: sine curve through tmin and tmax of the day
+// - double_sine: sine curve from tmin to tmax of the day and from tmax to tmin of the next day
+// - single_triangle: triangle through tmin and tmax of the day
+// for linear and cutoff responses the degree days are calculated analytically
+// (base temperature as lower threshold, TMax of the cutoff response as horizontal upper threshold),
+// for non-linear responses the response function is integrated over the diurnal temperature curve
+
+// thermal time methods
+const (
+	MethodTavg           = "tavg"
+	MethodAveraging      = "averaging"
+	MethodSingleSine     = "single_sine"
+	MethodDoubleSine     = "double_sine"
+	MethodSingleTriangle = "single_triangle"
+)
+
+// number of steps to integrate non-linear response functions over a day
+const diurnalSteps = 48
+
+// validate thermal time method
+func validateThermalTimeMethod(method string) error {
+	switch method {
+	case "", MethodTavg, MethodAveraging, MethodSingleSine, MethodDoubleSine, MethodSingleTriangle:
+		return nil
+	}
+	return fmt.Errorf("unknown thermal time method %q", method)
+}
+
+// thermal time method requires tmin and tmax
+func usesMinMaxTemp(method string) bool {
+	return method == MethodAveraging || method == MethodSingleSine || method == MethodDoubleSine || method == MethodSingleTriangle
+}
+
+// daily thermal time of a stage, with the given method
+// tminNext is the minimum temperature of the following day (used by double sine)
+func dailyThermalTime(method string, stage *Stage, tavg, tmin, tmax, tminNext float64) float64 {
+	switch method {
+	case MethodAveraging:
+		return stage.Response.thermalTime((tmin+tmax)/2, stage.BaseTemp)
+	case MethodSingleSine:
+		return curveThermalTime(sineArea, sineTemp, stage, tmin, tmax)
+	case MethodDoubleSine:
+		return 0.5*curveThermalTime(sineArea, sineTemp, stage, tmin, tmax) +
+			0.5*curveThermalTime(sineArea, sineTemp, stage, tminNext, tmax)
+	case MethodSingleTriangle:
+		return curveThermalTime(triangleArea, triangleTemp, stage, tmin, tmax)
+	default:
+		return stage.Response.thermalTime(tavg, stage.BaseTemp)
+	}
+}
+
+// thermal time over a diurnal temperature curve between tmin and tmax
+func curveThermalTime(area func(tmin, tmax, threshold float64) float64, curve func(tmin, tmax, fraction float64) float64, stage *Stage, tmin, tmax float64) float64 {
+	if tmin > tmax {
+		tmin, tmax = tmax, tmin
+	}
+	r := stage.Response
+	if r == nil || r.Type == "" || r.Type == ResponseLinear {
+		return area(tmin, tmax, stage.BaseTemp)
+	}
+	if r.Type == ResponseCutoff {
+		// horizontal cutoff, degree days above the upper threshold are removed
+		return area(tmin, tmax, stage.BaseTemp) - area(tmin, tmax, r.TMax)
+	}
+	// integrate non-linear response over the day
+	sum := 0.0
+	for i := 0; i < diurnalSteps; i++ {
+		fraction := (float64(i) + 0.5) / diurnalSteps
+		sum += r.thermalTime(curve(tmin, tmax, fraction), stage.BaseTemp)
+	}
+	return sum / diurnalSteps
+}
+
+// degree days above threshold, for a sine curve between tmin and tmax
+func sineArea(tmin, tmax, threshold float64) float64 {
+	mean := (tmax + tmin) / 2
+	if tmin >= threshold {
+		return mean - threshold
+	}
+	if tmax <= threshold {
+		return 0
+	}
+	amplitude := (tmax - tmin) / 2
+	theta := math.Asin((threshold - mean) / amplitude)
+	return ((mean-threshold)*(math.Pi/2-theta) + amplitude*math.Cos(theta)) / math.Pi
+}
+
+// temperature of a sine curve between tmin and tmax at a fraction of the day
+func sineTemp(tmin, tmax, fraction float64) float64 {
+	return (tmax+tmin)/2 + (tmax-tmin)/2*math.Sin(2*math.Pi*fraction)
+}
+
+// degree days above threshold, for a triangle between tmin and tmax
+func triangleArea(tmin, tmax, threshold float64) float64 {
+	if tmin >= threshold {
+		return (tmax+tmin)/2 - threshold
+	}
+	if tmax <= threshold {
+		return 0
+	}
+	return (tmax - threshold) * (tmax - threshold) / (2 * (tmax - tmin))
+}
+
+// temperature of a triangle between tmin and tmax at a fraction of the day
+func triangleTemp(tmin, tmax, fraction float64) float64 {
+	if fraction < 0.5 {
+		return tmin + (tmax-tmin)*fraction*2
+	}
+	return tmax - (tmax-tmin)*(fraction-0.5)*2
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_dailyThermalTime(t *testing.T) {
+	linear := &Stage{BaseTemp: 10}
+	cutoff := &Stage{BaseTemp: 10, Response: &TempResponse{Type: ResponseCutoff, TMax: 30}}
+	beta := &Stage{BaseTemp: 10, Response: &TempResponse{Type: ResponseBeta, TOpt: 30, TMax: 42}}
+
+	type args struct {
+		method                     string
+		stage                      *Stage
+		tavg, tmin, tmax, tminNext float64
+	}
+	tests := []struct {
+		name string
+		args args
+		want float64
+	}{
+		{"default uses tavg", args{"", linear, 14, 0, 30, 0}, 4},
+		{"tavg", args{MethodTavg, linear, 14, 0, 30, 0}, 4},
+		{"averaging uses tmin and tmax", args{MethodAveraging, linear, 14, 0, 30, 0}, 5},
+		{"averaging below base", args{MethodAveraging, linear, 14, 2, 16, 0}, 0},
+		{"sine above base", args{MethodSingleSine, linear, 0, 12, 20, 0}, 6},
+		{"sine below base", args{MethodSingleSine, linear, 0, 2, 8, 0}, 0},
+		// base temperature at the mean, area is amplitude / pi
+		{"sine around base", args{MethodSingleSine, linear, 0, 0, 20, 0}, 10 / math.Pi},
+		{"triangle around base", args{MethodSingleTriangle, linear, 0, 0, 20, 0}, 2.5},
+		{"triangle cutoff", args{MethodSingleTriangle, cutoff, 0, 20, 40, 0}, 20 - 2.5},
+		{"double sine", args{MethodDoubleSine, linear, 0, 12, 20, 16}, 0.5*6 + 0.5*8},
+		{"sine beta at optimum", args{MethodSingleSine, beta, 0, 30, 30, 30}, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := dailyThermalTime(tt.args.method, tt.args.stage, tt.args.tavg, tt.args.tmin, tt.args.tmax, tt.args.tminNext)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("dailyThermalTime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// days with tmin below base and tmax above base get degree days with sine, but not with tmin and tmax averaging
+	if v := dailyThermalTime(MethodSingleSine, linear, 9, 2, 16, 2); v <= 0 {
+		t.Errorf("dailyThermalTime() single sine = %v, want > 0", v)
+	}
+}
+
+func Test_usesMinMaxTemp(t *testing.T) {
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{"", false},
+		{MethodTavg, false},
+		{MethodAveraging, true},
+		{MethodSingleSine, true},
+		{MethodDoubleSine, true},
+		{MethodSingleTriangle, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			if got := usesMinMaxTemp(tt.method); got != tt.want {
+				t.Errorf("usesMinMaxTemp(%q) = %v, want %v", tt.method, got, tt.want)
+			}
+		})
+	}
+}
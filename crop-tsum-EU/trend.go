@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// trend of yearly results over the simulated years
+// slope with the Theil-Sen estimator (median of the slopes of all pairs of years),
+// significance with the two-sided Mann-Kendall test (normal approximation, with correction for ties)
+
+// missing values (NaN) are skipped
+
+// Sen's slope per year, NaN for less than two values
+func sensSlope(values []float64) float64 {
+	n := len(values)
+	slopes := make([]float64, 0, n*(n-1)/2)
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if math.IsNaN(values[i]) || math.IsNaN(values[j]) {
+				continue
+			}
+			slopes = append(slopes, (values[j]-values[i])/float64(j-i))
+		}
+	}
+	if len(slopes) == 0 {
+		return math.NaN()
+	}
+	sort.Float64s(slopes)
+	return quantile(slopes, 50)
+}
+
+// two-sided p-value of the Mann-Kendall trend test, NaN for less than three values
+func mannKendallP(values []float64) float64 {
+	values = withoutNaN(values)
+	n := len(values)
+	if n < 3 {
+		return math.NaN()
+	}
+	s := 0.0
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			switch {
+			case values[j] > values[i]:
+				s++
+			case values[j] < values[i]:
+				s--
+			}
+		}
+	}
+	// variance of S, corrected for groups of tied values
+	variance := float64(n*(n-1)*(2*n+5)) / 18
+	ties := make(map[float64]int)
+	for _, v := range values {
+		ties[v]++
+	}
+	for _, t := range ties {
+		if t > 1 {
+			variance -= float64(t*(t-1)*(2*t+5)) / 18
+		}
+	}
+	if variance <= 0 {
+		// all values equal, no trend
+		return 1
+	}
+	z := 0.0
+	if s > 0 {
+		z = (s - 1) / math.Sqrt(variance)
+	} else if s < 0 {
+		z = (s + 1) / math.Sqrt(variance)
+	}
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// trend of TSum and frost days of a reference
+func (result *CalculationResultRef) trends() {
+	tsum := result.validValues(result.Tsum)
+	frostDays := result.validValues(result.frostDays)
+	result.TsumTrend = sensSlope(tsum)
+	result.TsumTrendP = mannKendallP(tsum)
+	result.FrostTrend = sensSlope(frostDays)
+	result.FrostTrendP = mannKendallP(frostDays)
+}
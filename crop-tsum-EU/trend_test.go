@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_sensSlope(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{"linear", []float64{1, 3, 5, 7, 9}, 2},
+		{"outlier", []float64{1, 2, 3, 100, 5}, 1},
+		{"constant", []float64{4, 4, 4}, 0},
+		{"decreasing", []float64{10, 8, 6, 4}, -2},
+		{"missing", []float64{1, math.NaN(), 5, 7}, 2},
+		{"single value", []float64{1, math.NaN()}, math.NaN()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sensSlope(tt.values); math.Abs(got-tt.want) > 1e-9 && !(math.IsNaN(got) && math.IsNaN(tt.want)) {
+				t.Errorf("sensSlope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mannKendallP(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		// S = 45, var(S) = 125, z = 44 / sqrt(125)
+		{"increasing", []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, math.Erfc(44 / math.Sqrt(125) / math.Sqrt2)},
+		// S = 0
+		{"no trend", []float64{1, 3, 2, 2, 3, 1}, 1},
+		{"constant", []float64{5, 5, 5, 5}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mannKendallP(tt.values); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("mannKendallP() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if p := mannKendallP([]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}); p > 0.001 {
+		t.Errorf("mannKendallP() of a monotonic series = %v, want < 0.001", p)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+// vernalization of winter crops
+// a day with average temperature within the vernalization window counts as one vernalization day
+// vernalization days are accumulated from sowing, development of the vernalization stage
+// (and all following stages) is halted until the required vernalization days are reached
+
+// vernalization parameters of a crop
+type Vernalization struct {
+	MinTemp      float64 // lower temperature of the vernalization window
+	MaxTemp      float64 // upper temperature of the vernalization window
+	RequiredDays float64 // vernalization days required to continue development
+	Stage        string  // name of the stage delayed until vernalization is completed (default: second stage)
+}
+
+// validate vernalization parameters, returns index of the delayed stage
+func (v *Vernalization) validate(stages []Stage) (int, error) {
+	if v.MinTemp >= v.MaxTemp {
+		return -1, fmt.Errorf("vernalization: mintemp %v must be below maxtemp %v", v.MinTemp, v.MaxTemp)
+	}
+	if v.RequiredDays <= 0 {
+		return -1, fmt.Errorf("vernalization: requireddays must be greater than 0")
+	}
+	return v.stageIndex(stages)
+}
+
+// index of the stage delayed until vernalization is completed
+func (v *Vernalization) stageIndex(stages []Stage) (int, error) {
+	if v.Stage == "" {
+		if len(stages) > 1 {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	for idx, stage := range stages {
+		if stage.Name == v.Stage {
+			return idx, nil
+		}
+	}
+	return -1, fmt.Errorf("vernalization: unknown stage %q", v.Stage)
+}
+
+// count vernalization day
+func (v *Vernalization) isVernalizationDay(tavg float64) bool {
+	return tavg >= v.MinTemp && tavg <= v.MaxTemp
+}
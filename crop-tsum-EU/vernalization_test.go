@@ -0,0 +1,97 @@
+package main
+
+import "testing"
+
+func TestVernalization_isVernalizationDay(t *testing.T) {
+	vernalization := &Vernalization{MinTemp: 0, MaxTemp: 7, RequiredDays: 10}
+	tests := []struct {
+		name string
+		tavg float64
+		want bool
+	}{
+		{"below window", -1, false},
+		{"lower limit", 0, true},
+		{"within window", 4, true},
+		{"upper limit", 7, true},
+		{"above window", 8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := vernalization.isVernalizationDay(tt.tavg); got != tt.want {
+				t.Errorf("isVernalizationDay() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVernalization_validate(t *testing.T) {
+	stages := []Stage{{Name: "emergence"}, {Name: "flowering"}, {Name: "maturity"}}
+	tests := []struct {
+		name          string
+		vernalization Vernalization
+		want          int
+		wantErr       bool
+	}{
+		{"default stage", Vernalization{MinTemp: 0, MaxTemp: 7, RequiredDays: 10}, 1, false},
+		{"named stage", Vernalization{MinTemp: 0, MaxTemp: 7, RequiredDays: 10, Stage: "maturity"}, 2, false},
+		{"unknown stage", Vernalization{MinTemp: 0, MaxTemp: 7, RequiredDays: 10, Stage: "heading"}, -1, true},
+		{"empty window", Vernalization{MinTemp: 7, MaxTemp: 7, RequiredDays: 10}, -1, true},
+		{"no required days", Vernalization{MinTemp: 0, MaxTemp: 7}, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.vernalization.validate(stages)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_calculateCropPerWeatherVernalization(t *testing.T) {
+	// 20 vernalization days at 5 degC from DOY 1, then 10 degC
+	weather := testWeather(2001, 2001, func(year, doy int) weatherDay {
+		if doy <= 20 {
+			return weatherDay{tavg: 5, tmin: 5, tmax: 5}
+		}
+		return weatherDay{tavg: 10, tmin: 10, tmax: 10}
+	})
+	tests := []struct {
+		name              string
+		vernalization     *Vernalization
+		wantTsum          float64
+		wantInsufficient  int
+		wantVernalization float64
+	}{
+		{"without vernalization", nil, 900, 0, 0},
+		// emergence after 4 days, no development from DOY 5 to 9 until 10 vernalization days are reached
+		{"vernalization completed", &Vernalization{MinTemp: 0, MaxTemp: 7, RequiredDays: 10}, 875, 0, 20},
+		// development stops after emergence
+		{"vernalization insufficient", &Vernalization{MinTemp: 0, MaxTemp: 7, RequiredDays: 30}, 20, 1, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crop := &Crop{
+				TsumMaturity:  1000,
+				Stages:        []Stage{{Name: "emergence", Tsum: 20}, {Name: "maturity", Tsum: 980}},
+				Vernalization: tt.vernalization,
+			}
+			result := calculateCropPerWeather(crop, testTimeRanges(1, 1, 100), []int{1}, nil, 2001, 2001, weather, CalendarStandard, &OutputOptions{})[0]
+			if result.Tsum[0] != tt.wantTsum {
+				t.Errorf("Tsum = %v, want %v", result.Tsum[0], tt.wantTsum)
+			}
+			if tt.vernalization == nil {
+				return
+			}
+			if result.vernalizationDays[0] != tt.wantVernalization {
+				t.Errorf("vernalization days = %v, want %v", result.vernalizationDays[0], tt.wantVernalization)
+			}
+			if result.VernalizationInsufficient != tt.wantInsufficient {
+				t.Errorf("VernalizationInsufficient = %v, want %v", result.VernalizationInsufficient, tt.wantInsufficient)
+			}
+		})
+	}
+}
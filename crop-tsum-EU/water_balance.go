@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// climatic water balance and drought risk during the season
+// the daily water deficit is crop evapotranspiration (crop coefficient of the stage x reference
+// evapotranspiration) minus precipitation, summed up from sowing to maturity, for each stage
+// reference evapotranspiration is calculated with Hargreaves (tmin, tmax, latitude) or
+// FAO-56 Penman-Monteith, if the weather file has globrad (MJ m-2 d-1), wind (m s-1 at 2 m)
+// and relhumid (%) columns
+
+// reference evapotranspiration methods
+const (
+	ET0Hargreaves     = "hargreaves"
+	ET0PenmanMonteith = "penman_monteith"
+)
+
+// drought parameters of a crop
+type Drought struct {
+	MaxDeficit float64 // seasonal water deficit (mm) above which a year is a drought year
+	Method     string  // hargreaves, penman_monteith or empty (penman_monteith, if the weather file has the required columns)
+}
+
+// validate drought parameters
+func (d *Drought) validate() error {
+	switch d.Method {
+	case "", ET0Hargreaves, ET0PenmanMonteith:
+		return nil
+	}
+	return fmt.Errorf("drought: unknown evapotranspiration method %q", d.Method)
+}
+
+// crop coefficient of a stage, default 1
+func (s *Stage) cropCoefficient() float64 {
+	if s.Kc == nil {
+		return 1
+	}
+	return *s.Kc
+}
+
+// crop uses Penman-Monteith for the weather, without method if the weather has its columns
+func (c *Crop) usesPenmanMonteith(weather []weatherDay) bool {
+	return c.Drought != nil && (c.Drought.Method == ET0PenmanMonteith ||
+		(c.Drought.Method == "" && hasPenmanMonteithColumns(weather)))
+}
+
+// weather has the columns required for Penman-Monteith
+func hasPenmanMonteithColumns(weather []weatherDay) bool {
+	return hasWeatherColumn(weather, colGlobrad) &&
+		hasWeatherColumn(weather, colWind) &&
+		hasWeatherColumn(weather, colRelhumid)
+}
+
+// reference evapotranspiration (mm) of a day at latitude (degree)
+func referenceET(day weatherDay, latitude float64, penmanMonteith bool) float64 {
+	ra := extraterrestrialRadiation(latitude, day.doy)
+	if penmanMonteith {
+		return penmanMonteithET0(day.tmin, day.tmax, day.tavg, day.globrad, day.wind, day.relhumid, ra)
+	}
+	return hargreavesET0(day.tmin, day.tmax, day.tavg, ra)
+}
+
+// extraterrestrial radiation (MJ m-2 d-1), FAO-56 eq. 21
+func extraterrestrialRadiation(latitude float64, doy int) float64 {
+	lat := latitude * math.Pi / 180
+	declination := solarDeclination(doy)
+	// inverse relative distance earth-sun
+	dr := 1 + 0.033*math.Cos(2*math.Pi*float64(doy)/365)
+	// sunset hour angle
+	cosWs := math.Max(-1, math.Min(1, -math.Tan(lat)*math.Tan(declination)))
+	ws := math.Acos(cosWs)
+	ra := 24 * 60 / math.Pi * 0.0820 * dr *
+		(ws*math.Sin(lat)*math.Sin(declination) + math.Cos(lat)*math.Cos(declination)*math.Sin(ws))
+	return math.Max(0, ra)
+}
+
+// Hargreaves reference evapotranspiration (mm), FAO-56 eq. 52
+func hargreavesET0(tmin, tmax, tavg, ra float64) float64 {
+	et0 := 0.0023 * (tavg + 17.8) * math.Sqrt(math.Max(0, tmax-tmin)) * 0.408 * ra
+	return math.Max(0, et0)
+}
+
+// saturation vapour pressure (kPa) at temperature
+func saturationVapourPressure(temp float64) float64 {
+	return 0.6108 * math.Exp(17.27*temp/(temp+237.3))
+}
+
+// FAO-56 Penman-Monteith reference evapotranspiration (mm) at sea level
+// globrad in MJ m-2 d-1, wind at 2 m in m s-1, relhumid in %
+func penmanMonteithET0(tmin, tmax, tavg, globrad, wind, relhumid, ra float64) float64 {
+	const (
+		gamma  = 0.0674   // psychrometric constant (kPa/°C) at 101.3 kPa
+		sigma  = 4.903e-9 // Stefan-Boltzmann constant (MJ K-4 m-2 d-1)
+		albedo = 0.23
+	)
+	es := (saturationVapourPressure(tmax) + saturationVapourPressure(tmin)) / 2
+	ea := relhumid / 100 * es
+	delta := 4098 * saturationVapourPressure(tavg) / math.Pow(tavg+237.3, 2)
+
+	// net radiation
+	rns := (1 - albedo) * globrad
+	rso := 0.75 * ra
+	relRad := 1.0
+	if rso > 0 {
+		relRad = math.Min(1, globrad/rso)
+	}
+	rnl := sigma * (math.Pow(tmax+273.16, 4) + math.Pow(tmin+273.16, 4)) / 2 *
+		(0.34 - 0.14*math.Sqrt(math.Max(0, ea))) * (1.35*relRad - 0.35)
+	rn := rns - rnl
+
+	et0 := (0.408*delta*rn + gamma*900/(tavg+273)*wind*(es-ea)) / (delta + gamma*(1+0.34*wind))
+	return math.Max(0, et0)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_extraterrestrialRadiation(t *testing.T) {
+	tests := []struct {
+		name     string
+		latitude float64
+		doy      int
+		want     float64
+	}{
+		// FAO-56 example 8: 20 degS, 3 September
+		{"FAO-56 example 8", -20, 246, 32.2},
+		// FAO-56 example 18: Brussels 50 deg 48 min N, 6 July
+		{"FAO-56 example 18", 50.8, 187, 41.09},
+		{"polar night", 80, 355, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extraterrestrialRadiation(tt.latitude, tt.doy); math.Abs(got-tt.want) > 0.1 {
+				t.Errorf("extraterrestrialRadiation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_penmanMonteithET0(t *testing.T) {
+	// FAO-56 example 18: Brussels, 6 July, tmax 21.5, tmin 12.3, Rs 22.07 MJ m-2 d-1, u2 2.078 m s-1,
+	// actual vapour pressure 1.409 kPa (relhumid 70.6%), ET0 3.9 mm/day
+	ra := extraterrestrialRadiation(50.8, 187)
+	got := penmanMonteithET0(12.3, 21.5, 16.9, 22.07, 2.078, 70.6, ra)
+	if math.Abs(got-3.9) > 0.1 {
+		t.Errorf("penmanMonteithET0() = %v, want 3.9", got)
+	}
+}
+
+func TestStage_cropCoefficient(t *testing.T) {
+	kc := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name  string
+		stage Stage
+		want  float64
+	}{
+		{"default", Stage{}, 1},
+		{"kc 0", Stage{Kc: kc(0)}, 0},
+		{"kc", Stage{Kc: kc(1.15)}, 1.15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stage.cropCoefficient(); got != tt.want {
+				t.Errorf("cropCoefficient() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readCropDataKc(t *testing.T) {
+	tests := []struct {
+		name    string
+		kc      string
+		wantErr bool
+	}{
+		{"kc 0", "0", false},
+		{"negative kc", "-0.5", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cropFile := filepath.Join(t.TempDir(), "crop.yml")
+			data := "name: test\ntsummaturity: 100\nstages:\n- name: maturity\n  tsum: 100\n  kc: " + tt.kc + "\n"
+			if err := os.WriteFile(cropFile, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			crop, err := readCropData(cropFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readCropData() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && crop.Stages[0].cropCoefficient() != 0 {
+				t.Errorf("cropCoefficient() = %v, want 0", crop.Stages[0].cropCoefficient())
+			}
+		})
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// daily weather record
+type weatherDay struct {
+	year   int
+	doy    int
+	tavg   float64 // average temperature
+	tmin   float64 // minimum temperature
+	tmax   float64 // maximum temperature (NaN, if not in weather file)
+	precip float64 // precipitation
+
+	globrad  float64 // global radiation, MJ m-2 d-1 (NaN, if not in weather file)
+	wind     float64 // wind speed, m s-1 (NaN, if not in weather file)
+	relhumid float64 // relative humidity, % (NaN, if not in weather file)
+
+	filled bool // missing values of this day are filled
+}
+
+// weather csv columns
+const (
+	colDate = iota
+	colTavg
+	colTmin
+	colTmax
+	colPrecip
+	colGlobrad
+	colWind
+	colRelhumid
+	numWeatherColumns
+)
+
+// names of the weather csv columns, used as keys in the weather format
+var weatherColumnNames = [numWeatherColumns]string{"date", "tavg", "tmin", "tmax", "precip", "globrad", "wind", "relhumid"}
+
+// use of a weather column by a run
+type columnUse int
+
+const (
+	columnUnused   columnUse = iota // not read, NaN in the weather records
+	columnOptional                  // read, if in the weather data
+	columnRequired                  // must be in the weather data
+)
+
+// use of the weather columns by a run, indexed by weather csv column
+type weatherColumns [numWeatherColumns]columnUse
+
+// weather columns without crops (quality check): date, tavg, tmin and precip are required,
+// all other columns are read, if in the weather data
+func defaultWeatherColumns() *weatherColumns {
+	columns := &weatherColumns{}
+	for column := range columns {
+		columns[column] = columnOptional
+	}
+	for _, column := range []int{colDate, colTavg, colTmin, colPrecip} {
+		columns[column] = columnRequired
+	}
+	return columns
+}
+
+// weather columns of a run, optional columns are only read for crops using them
+// if tavg is derived by the missing value policy, tmin and tmax are required instead of tavg
+func newWeatherColumns(crops []*Crop, policy *MissingPolicy) *weatherColumns {
+	columns := defaultWeatherColumns()
+	for column, use := range columns {
+		if use == columnOptional {
+			columns[column] = columnUnused
+		}
+	}
+	for _, crop := range crops {
+		if crop.requiresTmax() {
+			columns[colTmax] = columnOptional
+		}
+		// Penman-Monteith, if selected or if the weather data has its columns
+		if crop.Drought != nil && crop.Drought.Method != ET0Hargreaves {
+			for _, column := range []int{colGlobrad, colWind, colRelhumid} {
+				columns[column] = columnOptional
+			}
+		}
+	}
+	if policy != nil && policy.DeriveTavg {
+		columns[colTavg] = columnOptional
+		columns[colTmax] = columnRequired
+	}
+	return columns
+}
+
+// weather csv format
+// default is the format of the SoybeanEU weather files:
+// two header lines, comma separated, date as YYYY-MM-DD in column iso-date or date
+// example for DWD daily station data (produkt_klima_tag_*.txt):
+//   headerlines: 1
+//   delimiter: ";"
+//   dateformat: YYYYMMDD
+//   columns:
+//     date: [MESS_DATUM]
+//     tavg: [TMK]
+//     tmin: [TNK]
+//     tmax: [TXK]
+//     precip: [RSK]
+//     wind: [FM]
+//     relhumid: [UPM]
+
+// format of weather csv files
+type WeatherFormat struct {
+	HeaderLines *int                `yaml:"headerlines,omitempty"` // number of header lines, column names are searched in all header lines (default 2)
+	Delimiter   string              `yaml:"delimiter,omitempty"`   // column delimiter, "tab" or "whitespace" (default ",")
+	DateFormat  string              `yaml:"dateformat,omitempty"`  // date format with YYYY, MM, DD or a Go time layout (default YYYY-MM-DD)
+	Columns     map[string][]string `yaml:"columns,omitempty"`     // column name aliases per weather column, e.g. tavg: [TMK, tas]
+	Units       map[string]string   `yaml:"units,omitempty"`       // units per weather column, converted to degC, mm, MJ m-2 d-1, m s-1, e.g. tavg: K
+	Missing     []string            `yaml:"missing,omitempty"`     // markers of missing values (default empty field, NA, -9999)
+}
+
+// default format of weather csv files
+func defaultWeatherFormat() *WeatherFormat {
+	headerLines := 2
+	return &WeatherFormat{
+		HeaderLines: &headerLines,
+		Delimiter:   ",",
+		DateFormat:  "YYYY-MM-DD",
+		Missing:     []string{"", "NA", "-9999"},
+	}
+}
+
+// read weather format from yml file
+func readWeatherFormat(filename string) (*WeatherFormat, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	format := &WeatherFormat{}
+	err = yaml.Unmarshal(data, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := format.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return format, nil
+}
+
+// set defaults for unset parameters and validate
+func (f *WeatherFormat) validate() error {
+	defaults := defaultWeatherFormat()
+	if f.HeaderLines == nil {
+		f.HeaderLines = defaults.HeaderLines
+	}
+	if *f.HeaderLines < 1 {
+		return fmt.Errorf("weather format: headerlines %d must be at least 1, column names are read from the header", *f.HeaderLines)
+	}
+	if f.Delimiter == "" {
+		f.Delimiter = defaults.Delimiter
+	}
+	if f.DateFormat == "" {
+		f.DateFormat = defaults.DateFormat
+	}
+	if f.Missing == nil {
+		f.Missing = defaults.Missing
+	}
+	for column := range f.Columns {
+		if weatherColumnIndex(column) < 0 {
+			return fmt.Errorf("weather format: unknown column %s", column)
+		}
+	}
+	for column, units := range f.Units {
+		if weatherColumnIndex(column) < 0 {
+			return fmt.Errorf("weather format: unknown column %s", column)
+		}
+		if _, _, ok := unitConversion(units); !ok {
+			return fmt.Errorf("weather format: unknown units %q of column %s", units, column)
+		}
+	}
+	return nil
+}
+
+// index of a weather column name, -1 if unknown
+func weatherColumnIndex(name string) int {
+	for idx, columnName := range weatherColumnNames {
+		if columnName == name {
+			return idx
+		}
+	}
+	return -1
+}
+
+// column names of a weather column: aliases of the format and the default names
+func (f *WeatherFormat) columnAliases(idx int) []string {
+	aliases := append([]string(nil), f.Columns[weatherColumnNames[idx]]...)
+	if idx == colDate {
+		return append(aliases, "iso-date", "date")
+	}
+	return append(aliases, weatherColumnNames[idx])
+}
+
+// split a line into fields
+func (f *WeatherFormat) split(line string) []string {
+	var fields []string
+	switch f.Delimiter {
+	case "whitespace":
+		return strings.Fields(line)
+	case "tab":
+		fields = strings.Split(line, "\t")
+	default:
+		fields = strings.Split(line, f.Delimiter)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
+
+// Go time layout of the date format
+func (f *WeatherFormat) dateLayout() string {
+	return strings.NewReplacer("YYYY", "2006", "MM", "01", "DD", "02").Replace(f.DateFormat)
+}
+
+// factor and offset to convert units to the units of the weather records, ok if units are known
+// temperature K -> degC, precipitation flux kg m-2 s-1 -> mm per day,
+// radiation W m-2 -> MJ m-2 d-1, J cm-2 -> MJ m-2, wind km h-1 -> m s-1
+func unitConversion(units string) (factor, offset float64, ok bool) {
+	switch strings.Join(strings.Fields(units), " ") {
+	case "", "degC", "C", "°C", "mm", "mm d-1", "MJ m-2", "MJ m-2 d-1", "m s-1", "m/s", "%":
+		return 1, 0, true
+	case "K", "Kelvin", "kelvin":
+		return 1, -273.15, true
+	case "kg m-2 s-1", "kg/m2/s", "kg m**-2 s**-1", "mm s-1", "mm/s":
+		return 86400, 0, true
+	case "W m-2", "W/m2", "W m**-2":
+		return 0.0864, 0, true
+	case "J cm-2", "J/cm2":
+		return 0.01, 0, true
+	case "km h-1", "km/h":
+		return 1 / 3.6, 0, true
+	}
+	return 1, 0, false
+}
+
+// read weather file, return daily records from start year to end year
+// format nil is the default format, dates are parsed in the calendar of the weather data
+// columns nil are the default columns, unused columns are not read
+// reading stops at the first line after the end year, unless scanAll is set (quality check of unordered files),
+// with scanAll all columns in the weather file are read
+func readWeatherFile(weatherFileName string, format *WeatherFormat, calendar Calendar, columns *weatherColumns, startYear, endYear int, scanAll bool) ([]weatherDay, error) {
+	if format == nil {
+		format = defaultWeatherFormat()
+	}
+	if columns == nil {
+		columns = defaultWeatherColumns()
+	}
+	// open weather file
+	weatherFile, err := os.Open(weatherFileName)
+	if err != nil {
+		return nil, err
+	}
+	defer weatherFile.Close()
+
+	days := make([]weatherDay, 0, (endYear-startYear+1)*366)
+	scanner := bufio.NewScanner(weatherFile)
+	headlines := *format.HeaderLines
+	layout := format.dateLayout()
+	// column index per weather column, -1 if not in weather file
+	idx := [numWeatherColumns]int{}
+	for i := range idx {
+		idx[i] = -1
+	}
+	// unit conversion per weather column
+	factor := [numWeatherColumns]float64{}
+	offset := [numWeatherColumns]float64{}
+	for i, name := range weatherColumnNames {
+		factor[i], offset[i], _ = unitConversion(format.Units[name])
+	}
+	// markers of missing values
+	missing := make(map[string]bool)
+	for _, marker := range format.Missing {
+		missing[marker] = true
+	}
+	lineNo := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+		// parse header line and get index for each weather column
+		if headlines > 0 {
+			fields := format.split(line)
+			for column := range idx {
+				if idx[column] >= 0 || (columns[column] == columnUnused && !scanAll) {
+					continue
+				}
+				for _, alias := range format.columnAliases(column) {
+					for fieldIdx, field := range fields {
+						if field == alias && idx[column] < 0 {
+							idx[column] = fieldIdx
+						}
+					}
+				}
+			}
+			headlines--
+			if headlines == 0 {
+				for column, use := range columns {
+					if use == columnRequired && idx[column] < 0 {
+						return nil, fmt.Errorf("%s: missing column %s", weatherFileName, strings.Join(format.columnAliases(column), "/"))
+					}
+				}
+			}
+			continue
+		}
+		// split line
+		fields := format.split(line)
+		if len(fields) == 1 && fields[0] == "" {
+			// skip empty lines
+			continue
+		}
+		for column, fieldIdx := range idx {
+			if fieldIdx >= len(fields) {
+				return nil, fmt.Errorf("%s: line %d: missing field %s", weatherFileName, lineNo, weatherColumnNames[column])
+			}
+		}
+		// parse date, convert date to DOY
+		year, doy, err := calendar.parseDate(layout, fields[idx[colDate]])
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", weatherFileName, lineNo, err)
+		}
+		// check if year is in range
+		if year < startYear {
+			continue
+		}
+		if year > endYear {
+			if scanAll {
+				continue
+			}
+			break
+		}
+		// parse values, missing values and columns not read or not in weather file are NaN
+		values := [numWeatherColumns]float64{}
+		for column := colTavg; column < numWeatherColumns; column++ {
+			values[column] = math.NaN()
+			if idx[column] < 0 || missing[fields[idx[column]]] {
+				continue
+			}
+			value, err := strconv.ParseFloat(fields[idx[column]], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: line %d: %s: %w", weatherFileName, lineNo, weatherColumnNames[column], err)
+			}
+			values[column] = value*factor[column] + offset[column]
+		}
+		days = append(days, weatherDay{
+			year:     year,
+			doy:      doy,
+			tavg:     values[colTavg],
+			tmin:     values[colTmin],
+			tmax:     values[colTmax],
+			precip:   values[colPrecip],
+			globrad:  values[colGlobrad],
+			wind:     values[colWind],
+			relhumid: values[colRelhumid],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return days, nil
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// missing values in weather data
+// missing values are NaN in the weather records (empty fields, NA, -9999 in csv files, _FillValue in NetCDF files)
+// and are filled with the selected policies, in this order:
+// - tavg derived from (tmin + tmax) / 2
+// - linear interpolation of gaps up to a maximum number of days
+// - climatological fill with the mean of the same DOY in the other years
+// only the weather columns used by the crops are filled and checked,
+// values still missing are an error, or make the seasons containing them invalid
+// invalid seasons are excluded from the aggregated results
+
+// policies to handle missing weather values
+type MissingPolicy struct {
+	DeriveTavg  bool `yaml:"derivetavg,omitempty"`  // derive missing tavg from (tmin + tmax) / 2, tavg is optional in the weather data
+	Interpolate int  `yaml:"interpolate,omitempty"` // interpolate gaps up to this number of days linearly
+	Climatology bool `yaml:"climatology,omitempty"` // fill remaining gaps with the mean of the same DOY in other years
+	InvalidYear bool `yaml:"invalidyear,omitempty"` // mark seasons with remaining gaps invalid, instead of an error
+}
+
+// parse missing value policy from command line, e.g. derivetavg,interpolate=3,climatology,invalidyear
+// returns nil for an empty string
+func parseMissingPolicy(value string) (*MissingPolicy, error) {
+	if value == "" {
+		return nil, nil
+	}
+	policy := &MissingPolicy{}
+	for _, field := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(field), "=")
+		switch name {
+		case "derivetavg":
+			policy.DeriveTavg = true
+		case "interpolate":
+			days, err := strconv.Atoi(arg)
+			if err != nil {
+				return nil, fmt.Errorf("missing policy %q: interpolate=<days>: %w", value, err)
+			}
+			policy.Interpolate = days
+		case "climatology":
+			policy.Climatology = true
+		case "invalidyear":
+			policy.InvalidYear = true
+		default:
+			return nil, fmt.Errorf("missing policy %q: unknown policy %s", value, name)
+		}
+	}
+	return policy, policy.validate()
+}
+
+// validate missing value policy
+func (p *MissingPolicy) validate() error {
+	if p.Interpolate < 0 {
+		return fmt.Errorf("missing policy: interpolate %d must not be negative", p.Interpolate)
+	}
+	return nil
+}
+
+// weather values of a day, indexed by weather csv column (nil for the date)
+func (day *weatherDay) values() [numWeatherColumns]*float64 {
+	return [numWeatherColumns]*float64{nil, &day.tavg, &day.tmin, &day.tmax, &day.precip, &day.globrad, &day.wind, &day.relhumid}
+}
+
+// weather data has values of a weather csv column
+func hasWeatherColumn(weather []weatherDay, column int) bool {
+	for dayIdx := range weather {
+		if !math.IsNaN(*weather[dayIdx].values()[column]) {
+			return true
+		}
+	}
+	return false
+}
+
+// weather source, with missing values filled by a policy
+// only the weather columns used by the crops are filled,
+// without policy, missing values in these columns are an error
+type filledWeatherSource struct {
+	WeatherSource
+	crops  []*Crop
+	policy *MissingPolicy
+}
+
+// read weather of a grid code and fill missing values
+func (s *filledWeatherSource) Read(gridCode string, startYear, endYear int) ([]weatherDay, error) {
+	weather, err := s.WeatherSource.Read(gridCode, startYear, endYear)
+	if err != nil {
+		return nil, err
+	}
+	return weather, fillMissing(weather, s.policy, usedSeasonColumns(s.crops, weather))
+}
+
+// fill missing values of the weather columns, days with filled values are marked
+// optional columns without any value (not in the weather data) are not filled,
+// required columns without any value are an error
+func fillMissing(weather []weatherDay, policy *MissingPolicy, columns []int) error {
+	if policy == nil {
+		policy = &MissingPolicy{}
+	}
+	// tavg from tmin and tmax, also if tavg is not in the weather data
+	if policy.DeriveTavg {
+		for dayIdx := range weather {
+			day := &weather[dayIdx]
+			if math.IsNaN(day.tavg) && !math.IsNaN(day.tmin) && !math.IsNaN(day.tmax) {
+				day.tavg = (day.tmin + day.tmax) / 2
+				day.filled = true
+			}
+		}
+	}
+	for _, column := range columns {
+		if !hasWeatherColumn(weather, column) {
+			continue
+		}
+		value := func(dayIdx int) *float64 {
+			return weather[dayIdx].values()[column]
+		}
+		// linear interpolation of short gaps
+		if policy.Interpolate > 0 {
+			for dayIdx := 0; dayIdx < len(weather); dayIdx++ {
+				if !math.IsNaN(*value(dayIdx)) {
+					continue
+				}
+				gapEnd := dayIdx
+				for gapEnd < len(weather) && math.IsNaN(*value(gapEnd)) {
+					gapEnd++
+				}
+				if dayIdx > 0 && gapEnd < len(weather) && gapEnd-dayIdx <= policy.Interpolate {
+					before := *value(dayIdx - 1)
+					after := *value(gapEnd)
+					for i := dayIdx; i < gapEnd; i++ {
+						fraction := float64(i-dayIdx+1) / float64(gapEnd-dayIdx+1)
+						*value(i) = before + fraction*(after-before)
+						weather[i].filled = true
+					}
+				}
+				dayIdx = gapEnd
+			}
+		}
+		// mean of the same DOY in other years
+		if policy.Climatology {
+			sum := make([]float64, 367)
+			count := make([]int, 367)
+			for dayIdx, day := range weather {
+				if v := *value(dayIdx); !math.IsNaN(v) {
+					sum[day.doy] += v
+					count[day.doy]++
+				}
+			}
+			for dayIdx := range weather {
+				doy := weather[dayIdx].doy
+				if math.IsNaN(*value(dayIdx)) && count[doy] > 0 {
+					*value(dayIdx) = sum[doy] / float64(count[doy])
+					weather[dayIdx].filled = true
+				}
+			}
+		}
+		// remaining missing values
+		if !policy.InvalidYear {
+			for dayIdx, day := range weather {
+				if math.IsNaN(*value(dayIdx)) {
+					return fmt.Errorf("missing %s in year %d, DOY %d", weatherColumnNames[column], day.year, day.doy)
+				}
+			}
+		}
+	}
+	// required columns without any value can not be filled
+	if len(weather) > 0 {
+		for column, use := range defaultWeatherColumns() {
+			if column != colDate && use == columnRequired && !hasWeatherColumn(weather, column) {
+				return fmt.Errorf("no values of %s in the weather data", weatherColumnNames[column])
+			}
+		}
+	}
+	return nil
+}
+
+// weather columns used by the season calculation of a crop
+func requiredSeasonColumns(crop *Crop, penmanMonteith bool) []int {
+	columns := []int{colTavg, colTmin, colPrecip}
+	if crop.requiresTmax() {
+		columns = append(columns, colTmax)
+	}
+	if penmanMonteith {
+		columns = append(columns, colGlobrad, colWind, colRelhumid)
+	}
+	return columns
+}
+
+// weather columns used by the season calculation of the crops
+func usedSeasonColumns(crops []*Crop, weather []weatherDay) []int {
+	used := [numWeatherColumns]bool{}
+	for _, crop := range crops {
+		for _, column := range requiredSeasonColumns(crop, crop.usesPenmanMonteith(weather)) {
+			used[column] = true
+		}
+	}
+	var columns []int
+	for column := colTavg; column < numWeatherColumns; column++ {
+		if used[column] {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// weather columns with missing values, used by the season calculation of a crop
+func missingSeasonColumns(crop *Crop, weather []weatherDay, penmanMonteith bool) []int {
+	var missing []int
+	for _, column := range requiredSeasonColumns(crop, penmanMonteith) {
+		for dayIdx := range weather {
+			if math.IsNaN(*weather[dayIdx].values()[column]) {
+				missing = append(missing, column)
+				break
+			}
+		}
+	}
+	return missing
+}
+
+// check a season for missing values in the weather columns used by the crop,
+// including the precipitation of the wet harvest window, and count the days with filled values
+func (setup *seasonSetup) checkSeason(season seasonRange) (complete bool, filledDays int) {
+	for dayIdx := season.first; dayIdx <= season.last; dayIdx++ {
+		day := &setup.weather[dayIdx]
+		values := day.values()
+		for _, column := range setup.missingColumns {
+			if math.IsNaN(*values[column]) {
+				return false, 0
+			}
+		}
+		if day.filled {
+			filledDays++
+		}
+	}
+	// precipitation of the wet harvest window, which may end after the harvest
+	for _, column := range setup.missingColumns {
+		if column != colPrecip {
+			continue
+		}
+		last := min(season.last+*setup.harvestRain.Offset, len(setup.weather)-1)
+		for dayIdx := season.last + 1; dayIdx <= last; dayIdx++ {
+			if math.IsNaN(setup.weather[dayIdx].precip) {
+				return false, 0
+			}
+		}
+	}
+	return true, filledDays
+}
+
+// yearly values of a reference, NaN for invalid years
+func (result *CalculationResultRef) validValues(values []float64) []float64 {
+	if result.ValidYears == len(values) {
+		return values
+	}
+	valid := make([]float64, len(values))
+	for yearIdx, value := range values {
+		if result.invalidYears[yearIdx] {
+			value = math.NaN()
+		}
+		valid[yearIdx] = value
+	}
+	return valid
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// quality check of weather input, subcommand validate-weather
+// all weather files of the grid codes in the reference file are read and checked for:
+// - dates in ascending order, without duplicated days
+// - continuous dates, without missing days
+// - coverage of start year to end year
+// - tmin <= tavg <= tmax
+// - plausible ranges of the weather values
+// missing values (empty, NA, -9999) are counted
+// a file that can not be read is reported as error, the check continues with the next file
+// output:
+// - qc_weather_<start>-<end>.csv, one line per grid code
+// - qc_weather_summary_<start>-<end>.txt, number of grid codes with each problem (also printed)
+
+// plausible range of a weather column, after unit conversion
+type plausibleRange struct {
+	min, max float64
+}
+
+// plausible ranges per weather column
+var plausibleRanges = map[int]plausibleRange{
+	colTavg:     {-60, 50},
+	colTmin:     {-70, 45},
+	colTmax:     {-50, 60},
+	colPrecip:   {0, 500},
+	colGlobrad:  {0, 50},
+	colWind:     {0, 75},
+	colRelhumid: {0, 100},
+}
+
+// quality check result of the weather of a grid code
+type weatherQC struct {
+	gridCode     string
+	err          error  // weather could not be read
+	days         int    // number of days from start year to end year
+	firstDate    string // first date in the weather data
+	lastDate     string // last date in the weather data
+	unordered    int    // days before the previous day
+	duplicates   int    // days equal to the previous day
+	gaps         int    // missing days between two consecutive days
+	complete     bool   // start year to end year covered
+	missing      int    // days with missing values
+	tempOrder    int    // days with tmin > tavg or tavg > tmax
+	outOfRange   int    // days with values outside the plausible range
+	outOfRangeAt string // first weather column outside the plausible range
+}
+
+// weather of the grid code passed all checks
+func (qc *weatherQC) ok() bool {
+	return qc.err == nil && qc.complete && qc.unordered == 0 && qc.duplicates == 0 && qc.gaps == 0 &&
+		qc.missing == 0 && qc.tempOrder == 0 && qc.outOfRange == 0
+}
+
+// check the weather days of a grid code, from start year to end year
+func checkWeather(gridCode string, weather []weatherDay, calendar Calendar, startYear, endYear int) *weatherQC {
+	qc := &weatherQC{gridCode: gridCode, days: len(weather)}
+	if len(weather) == 0 {
+		return qc
+	}
+	// required columns and optional columns in the weather data
+	required := defaultWeatherColumns()
+	var columns []int
+	for column := colTavg; column < numWeatherColumns; column++ {
+		if required[column] == columnRequired || hasWeatherColumn(weather, column) {
+			columns = append(columns, column)
+		}
+	}
+	first, last := weather[0], weather[0]
+	for dayIdx := range weather {
+		day := &weather[dayIdx]
+		// order and continuity of dates
+		if dayIdx > 0 {
+			prev := &weather[dayIdx-1]
+			switch {
+			case day.year < prev.year || (day.year == prev.year && day.doy < prev.doy):
+				qc.unordered++
+			case day.year == prev.year && day.doy == prev.doy:
+				qc.duplicates++
+			default:
+				qc.gaps += calendar.daysBetween(prev.year, prev.doy, day.year, day.doy) - 1
+			}
+		}
+		if day.year < first.year || (day.year == first.year && day.doy < first.doy) {
+			first = *day
+		}
+		if day.year > last.year || (day.year == last.year && day.doy > last.doy) {
+			last = *day
+		}
+		// missing values and plausible ranges
+		values := day.values()
+		missing, outOfRange := false, false
+		for _, column := range columns {
+			value := *values[column]
+			if math.IsNaN(value) {
+				missing = true
+				continue
+			}
+			if r := plausibleRanges[column]; value < r.min || value > r.max {
+				if qc.outOfRangeAt == "" {
+					qc.outOfRangeAt = fmt.Sprintf("%s %s %g", weatherColumnNames[column], calendar.dateString(day.year, day.doy), value)
+				}
+				outOfRange = true
+			}
+		}
+		if missing {
+			qc.missing++
+		}
+		if outOfRange {
+			qc.outOfRange++
+		}
+		// tmin <= tavg <= tmax, tmax is optional
+		if day.tmin > day.tavg || day.tavg > day.tmax {
+			qc.tempOrder++
+		}
+	}
+	qc.firstDate = calendar.dateString(first.year, first.doy)
+	qc.lastDate = calendar.dateString(last.year, last.doy)
+	qc.complete = first.year == startYear && first.doy == 1 && last.year == endYear && last.doy == calendar.daysInYear(endYear)
+	return qc
+}
+
+// subcommand validate-weather, check all weather files of the reference file and write a report
+func validateWeatherCommand(args []string) error {
+	flags := flag.NewFlagSet("validate-weather", flag.ExitOnError)
+	pathToWeather := flags.String("weather", "weather", "path to weather files, template with %s for the grid code, or NetCDF file(s) (*.nc)")
+	weatherFormatFile := flags.String("weather_format", "", "weather csv format file (yml), default SoybeanEU weather format")
+	referenceFile := flags.String("reference", "stu_eu_layer_ref.csv", "reference file climate sowing date mapping")
+	startYear := flags.Int("start_year", 1981, "start year")
+	endYear := flags.Int("end_year", 2010, "end year")
+	outputFolder := flags.String("output", "./output", "output folder for the report")
+	workers := flags.Int("workers", runtime.NumCPU(), "number of weather files checked in parallel")
+	calendarName := flags.String("calendar", "", "calendar of the weather data: standard, noleap (365_day), 360_day (default standard, or the calendar of NetCDF files)")
+	flags.Parse(args)
+
+	if *startYear > *endYear {
+		return fmt.Errorf("start year %d is after end year %d", *startYear, *endYear)
+	}
+	calendar, err := parseCalendar(*calendarName)
+	if err != nil {
+		return err
+	}
+	var weatherFormat *WeatherFormat
+	if *weatherFormatFile != "" {
+		weatherFormat, err = readWeatherFormat(*weatherFormatFile)
+		if err != nil {
+			return err
+		}
+	}
+	_, gridCodeToReferences, err := readClimateRefData(*referenceFile)
+	if err != nil {
+		return err
+	}
+	source, err := newWeatherSource(*pathToWeather, weatherFormat, calendar, nil)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	// lines after a misplaced line of a later year are read and checked
+	if csvSource, ok := source.(*csvWeatherSource); ok {
+		csvSource.scanAll = true
+	}
+
+	results := validateAllWeatherFiles(gridCodeToReferences, source, *startYear, *endYear, *workers)
+
+	if err := os.MkdirAll(*outputFolder, 0755); err != nil {
+		return err
+	}
+	if err := writeWeatherQC(results, *startYear, *endYear, *outputFolder); err != nil {
+		return err
+	}
+	summary := weatherQCSummary(results, *startYear, *endYear)
+	fmt.Print(summary)
+	summaryFileName := filepath.Join(*outputFolder, fmt.Sprintf("qc_weather_summary_%d-%d.txt", *startYear, *endYear))
+	if err := os.WriteFile(summaryFileName, []byte(summary), 0644); err != nil {
+		return err
+	}
+	failed := 0
+	for _, qc := range results {
+		if !qc.ok() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d grid codes failed the weather check", failed, len(results))
+	}
+	return nil
+}
+
+// check the weather of all grid codes, sorted by grid code
+// errors are reported per grid code, the check continues with the next grid code
+func validateAllWeatherFiles(gridCodeToReferences map[string][]int, source WeatherSource, startYear, endYear, workers int) []*weatherQC {
+	if workers < 1 {
+		workers = 1
+	}
+	gridCodes := make([]string, 0, len(gridCodeToReferences))
+	for gridCode := range gridCodeToReferences {
+		gridCodes = append(gridCodes, gridCode)
+	}
+	sort.Strings(gridCodes)
+
+	results := make([]*weatherQC, len(gridCodes))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				gridCode := gridCodes[idx]
+				weather, err := source.Read(gridCode, startYear, endYear)
+				if err != nil {
+					results[idx] = &weatherQC{gridCode: gridCode, err: err}
+					continue
+				}
+				results[idx] = checkWeather(gridCode, weather, source.Calendar(), startYear, endYear)
+			}
+		}()
+	}
+	for idx := range gridCodes {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// write weather quality check to csv file, one line per grid code
+func writeWeatherQC(results []*weatherQC, startYear, endYear int, outputFolder string) error {
+	csvFileName := filepath.Join(outputFolder, fmt.Sprintf("qc_weather_%d-%d.csv", startYear, endYear))
+	csvFile, err := createGzFileWriter(csvFileName)
+	if err != nil {
+		return err
+	}
+	defer csvFile.Close()
+	header := "gridCode,status,days,first_date,last_date,complete,unordered,duplicates,missing_days,missing_values,temp_order,out_of_range,message"
+	_, err = csvFile.Write(header + "\n")
+	if err != nil {
+		return err
+	}
+	for _, qc := range results {
+		status := "ok"
+		message := qc.outOfRangeAt
+		if qc.err != nil {
+			status = "error"
+			message = qc.err.Error()
+		} else if !qc.ok() {
+			status = "failed"
+		}
+		// message may contain the delimiter
+		message = strings.ReplaceAll(message, ",", ";")
+		line := fmt.Sprintf("%s,%s,%d,%s,%s,%t,%d,%d,%d,%d,%d,%d,%s", qc.gridCode, status, qc.days, qc.firstDate, qc.lastDate, qc.complete,
+			qc.unordered, qc.duplicates, qc.gaps, qc.missing, qc.tempOrder, qc.outOfRange, message)
+		_, err = csvFile.Write(line + "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summary of the weather quality check, number of grid codes with each problem
+func weatherQCSummary(results []*weatherQC, startYear, endYear int) string {
+	count := func(check func(qc *weatherQC) bool) int {
+		n := 0
+		for _, qc := range results {
+			if check(qc) {
+				n++
+			}
+		}
+		return n
+	}
+	readable := func(check func(qc *weatherQC) bool) func(qc *weatherQC) bool {
+		return func(qc *weatherQC) bool { return qc.err == nil && check(qc) }
+	}
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "weather check %d-%d\n", startYear, endYear)
+	fmt.Fprintf(&summary, "grid codes:                %d\n", len(results))
+	fmt.Fprintf(&summary, "ok:                        %d\n", count(func(qc *weatherQC) bool { return qc.ok() }))
+	fmt.Fprintf(&summary, "read errors:               %d\n", count(func(qc *weatherQC) bool { return qc.err != nil }))
+	fmt.Fprintf(&summary, "incomplete period:         %d\n", count(readable(func(qc *weatherQC) bool { return !qc.complete })))
+	fmt.Fprintf(&summary, "unordered dates:           %d\n", count(readable(func(qc *weatherQC) bool { return qc.unordered > 0 })))
+	fmt.Fprintf(&summary, "duplicated days:           %d\n", count(readable(func(qc *weatherQC) bool { return qc.duplicates > 0 })))
+	fmt.Fprintf(&summary, "missing days:              %d\n", count(readable(func(qc *weatherQC) bool { return qc.gaps > 0 })))
+	fmt.Fprintf(&summary, "missing values:            %d\n", count(readable(func(qc *weatherQC) bool { return qc.missing > 0 })))
+	fmt.Fprintf(&summary, "tmin <= tavg <= tmax:      %d\n", count(readable(func(qc *weatherQC) bool { return qc.tempOrder > 0 })))
+	fmt.Fprintf(&summary, "values out of range:       %d\n", count(readable(func(qc *weatherQC) bool { return qc.outOfRange > 0 })))
+	return summary.String()
+}
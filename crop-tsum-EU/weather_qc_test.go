@@ -0,0 +1,48 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func Test_checkWeather(t *testing.T) {
+	// days of 2000 (leap year) and 2001, as year and DOY
+	newWeather := func(dates ...[2]int) []weatherDay {
+		weather := make([]weatherDay, len(dates))
+		for i, date := range dates {
+			weather[i] = weatherDay{year: date[0], doy: date[1], tavg: 5, tmin: 0, tmax: 10, precip: 1, globrad: math.NaN(), wind: math.NaN(), relhumid: math.NaN()}
+		}
+		return weather
+	}
+	complete := newWeather([2]int{2000, 1}, [2]int{2000, 366}, [2]int{2001, 1}, [2]int{2001, 365})
+	tests := []struct {
+		name    string
+		weather []weatherDay
+		modify  func(weather []weatherDay)
+		want    weatherQC
+	}{
+		{"turn of the year", newWeather([2]int{2000, 366}, [2]int{2001, 1}), nil, weatherQC{days: 2}},
+		{"gap", newWeather([2]int{2000, 365}, [2]int{2001, 2}), nil, weatherQC{days: 2, gaps: 2}},
+		{"duplicate", newWeather([2]int{2000, 10}, [2]int{2000, 10}), nil, weatherQC{days: 2, duplicates: 1}},
+		{"unordered", newWeather([2]int{2000, 10}, [2]int{2000, 9}), nil, weatherQC{days: 2, unordered: 1}},
+		{"missing value", newWeather([2]int{2000, 10}), func(w []weatherDay) { w[0].precip = math.NaN() }, weatherQC{days: 1, missing: 1}},
+		{"tmin above tavg", newWeather([2]int{2000, 10}), func(w []weatherDay) { w[0].tmin = 6 }, weatherQC{days: 1, tempOrder: 1}},
+		{"out of range", newWeather([2]int{2000, 10}), func(w []weatherDay) { w[0].precip = -1 }, weatherQC{days: 1, outOfRange: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.modify != nil {
+				tt.modify(tt.weather)
+			}
+			got := checkWeather("1_1", tt.weather, CalendarStandard, 2000, 2001)
+			if got.days != tt.want.days || got.gaps != tt.want.gaps || got.duplicates != tt.want.duplicates || got.unordered != tt.want.unordered ||
+				got.missing != tt.want.missing || got.tempOrder != tt.want.tempOrder || got.outOfRange != tt.want.outOfRange || got.complete {
+				t.Errorf("checkWeather() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+	// first and last day of the period, gaps are counted separately
+	if got := checkWeather("1_1", complete, CalendarStandard, 2000, 2001); !got.complete || got.firstDate != "2000-01-01" || got.lastDate != "2001-12-31" {
+		t.Errorf("checkWeather() = %+v, want complete 2000-01-01 to 2001-12-31", *got)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// weather sources
+// - csv: one csv file per grid code, the weather path is a template with %s for the grid code
+// - netcdf: CF-NetCDF files (classic or 64-bit offset) with (time, y, x) variables,
+//   the weather path ends with .nc and is either a single file with all variables,
+//   or a template with %s for the variable name (one file per variable, e.g. tas, tasmin, pr)
+//   grid code row_col selects the grid cell y = row-1, x = col-1
+//   the calendar is read from the time coordinate variable
+
+// source of daily weather records per weather grid code
+type WeatherSource interface {
+	// daily records of a grid code, from start year to end year
+	Read(gridCode string, startYear, endYear int) ([]weatherDay, error)
+	// calendar of the weather data
+	Calendar() Calendar
+	// release open files
+	Close() error
+}
+
+// create weather source for a weather path
+// format of csv files, nil for the default format
+// calendar of the weather data, "" for the standard calendar of csv files or the calendar of NetCDF files
+// columns read from the weather data, nil for the default columns
+func newWeatherSource(weatherPath string, format *WeatherFormat, calendar Calendar, columns *weatherColumns) (WeatherSource, error) {
+	if columns == nil {
+		columns = defaultWeatherColumns()
+	}
+	if strings.HasSuffix(weatherPath, ".nc") {
+		return openNetCDFWeather(weatherPath, calendar, columns)
+	}
+	if calendar == "" {
+		calendar = CalendarStandard
+	}
+	return &csvWeatherSource{pathTemplate: weatherPath, format: format, calendar: calendar, columns: columns}, nil
+}
+
+// weather source with one csv file per grid code
+type csvWeatherSource struct {
+	pathTemplate string // weather file template, %s is replaced by the weather grid code
+	format       *WeatherFormat
+	calendar     Calendar
+	columns      *weatherColumns
+	scanAll      bool // read all lines of the weather files, also after the end year (quality check)
+}
+
+// read weather file of a grid code
+func (s *csvWeatherSource) Read(gridCode string, startYear, endYear int) ([]weatherDay, error) {
+	return readWeatherFile(fmt.Sprintf(s.pathTemplate, gridCode), s.format, s.calendar, s.columns, startYear, endYear, s.scanAll)
+}
+
+func (s *csvWeatherSource) Calendar() Calendar {
+	return s.calendar
+}
+
+func (s *csvWeatherSource) Close() error {
+	return nil
+}
+
+// weather variable in a NetCDF file, with candidate variable names (CORDEX, E-OBS, csv names)
+type netcdfQuantity struct {
+	names  []string
+	column int // weather csv column of the variable
+}
+
+// weather variables in the order of netcdfWeatherSource.vars
+var netcdfQuantities = []netcdfQuantity{
+	{[]string{"tas", "tg", "tavg"}, colTavg},
+	{[]string{"tasmin", "tn", "tmin"}, colTmin},
+	{[]string{"tasmax", "tx", "tmax"}, colTmax},
+	{[]string{"pr", "rr", "precip"}, colPrecip},
+	{[]string{"rsds", "qq", "globrad"}, colGlobrad},
+	{[]string{"sfcWind", "fg", "wind"}, colWind},
+	{[]string{"hurs", "hu", "relhumid"}, colRelhumid},
+}
+
+// weather source with CF-NetCDF files
+type netcdfWeatherSource struct {
+	files []*ncFile
+	vars  []*ncVar  // variable per quantity, nil if not available
+	nc    []*ncFile // file per quantity
+	years []int     // year per time step
+	doys  []int     // DOY per time step
+
+	calendar Calendar
+}
+
+// open NetCDF weather files and read time axis
+// calendar "" is the calendar of the files, otherwise it must match the calendar of the files
+// variables of required columns must be in the files
+func openNetCDFWeather(weatherPath string, calendar Calendar, columns *weatherColumns) (_ *netcdfWeatherSource, err error) {
+	source := &netcdfWeatherSource{
+		vars: make([]*ncVar, len(netcdfQuantities)),
+		nc:   make([]*ncFile, len(netcdfQuantities)),
+	}
+	// close opened files on error
+	defer func() {
+		if err != nil {
+			source.Close()
+		}
+	}()
+	opened := make(map[string]*ncFile)
+	open := func(name string) (*ncFile, error) {
+		if nc, ok := opened[name]; ok {
+			return nc, nil
+		}
+		nc, err := openNetCDF(name)
+		if err != nil {
+			return nil, err
+		}
+		opened[name] = nc
+		source.files = append(source.files, nc)
+		return nc, nil
+	}
+	perVariable := strings.Contains(weatherPath, "%s")
+	for idx, quantity := range netcdfQuantities {
+		if columns[quantity.column] == columnUnused {
+			continue
+		}
+		for _, name := range quantity.names {
+			fileName := weatherPath
+			if perVariable {
+				fileName = fmt.Sprintf(weatherPath, name)
+				if _, err := os.Stat(fileName); err != nil {
+					continue
+				}
+			}
+			nc, err := open(fileName)
+			if err != nil {
+				return nil, err
+			}
+			if v, ok := nc.vars[name]; ok {
+				source.vars[idx] = v
+				source.nc[idx] = nc
+				break
+			}
+		}
+		if columns[quantity.column] == columnRequired && source.vars[idx] == nil {
+			return nil, fmt.Errorf("%s: no variable %s", weatherPath, strings.Join(quantity.names, "/"))
+		}
+	}
+
+	// time axis, all variables must have the same time steps
+	for idx, v := range source.vars {
+		if v == nil {
+			continue
+		}
+		years, doys, fileCalendar, err := source.nc[idx].timeAxis(v)
+		if err != nil {
+			return nil, err
+		}
+		if calendar != "" && fileCalendar != calendar {
+			return nil, fmt.Errorf("%s: calendar %s does not match the scenario calendar %s", source.nc[idx].name, fileCalendar, calendar)
+		}
+		if source.years == nil {
+			source.years, source.doys, source.calendar = years, doys, fileCalendar
+			continue
+		}
+		if fileCalendar != source.calendar {
+			return nil, fmt.Errorf("%s: variable %s has a different calendar", source.nc[idx].name, v.name)
+		}
+		if len(years) != len(source.years) || years[0] != source.years[0] || doys[0] != source.doys[0] {
+			return nil, fmt.Errorf("%s: variable %s has a different time axis", source.nc[idx].name, v.name)
+		}
+	}
+	return source, nil
+}
+
+// close all NetCDF files
+func (s *netcdfWeatherSource) Close() error {
+	var firstErr error
+	for _, nc := range s.files {
+		if err := nc.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (s *netcdfWeatherSource) Calendar() Calendar {
+	return s.calendar
+}
+
+// read time series of the grid cell of a grid code
+func (s *netcdfWeatherSource) Read(gridCode string, startYear, endYear int) ([]weatherDay, error) {
+	y, x, err := parseGridCode(gridCode)
+	if err != nil {
+		return nil, err
+	}
+	series := make([][]float64, len(s.vars))
+	for idx, v := range s.vars {
+		if v == nil {
+			continue
+		}
+		series[idx], err = s.nc[idx].readTimeSeries(v, y, x)
+		if err != nil {
+			return nil, err
+		}
+		convertUnits(series[idx], v.attributeText("units"))
+	}
+	value := func(idx, t int) float64 {
+		if series[idx] == nil {
+			return math.NaN()
+		}
+		return series[idx][t]
+	}
+	days := make([]weatherDay, 0, (endYear-startYear+1)*366)
+	for t, year := range s.years {
+		if year < startYear || year > endYear {
+			continue
+		}
+		days = append(days, weatherDay{
+			year:     year,
+			doy:      s.doys[t],
+			tavg:     value(0, t),
+			tmin:     value(1, t),
+			tmax:     value(2, t),
+			precip:   value(3, t),
+			globrad:  value(4, t),
+			wind:     value(5, t),
+			relhumid: value(6, t),
+		})
+	}
+	return days, nil
+}
+
+// grid cell indices of a grid code row_col (1-based)
+func parseGridCode(gridCode string) (y, x int, err error) {
+	row, col, found := strings.Cut(gridCode, "_")
+	if !found {
+		return 0, 0, fmt.Errorf("grid code %s: expected row_col", gridCode)
+	}
+	y, err = strconv.Atoi(row)
+	if err != nil {
+		return 0, 0, fmt.Errorf("grid code %s: %w", gridCode, err)
+	}
+	x, err = strconv.Atoi(col)
+	if err != nil {
+		return 0, 0, fmt.Errorf("grid code %s: %w", gridCode, err)
+	}
+	return y - 1, x - 1, nil
+}
+
+// year and DOY of each time step of a variable, and the calendar of the time axis
+// the time coordinate variable has the name of the first dimension of the variable,
+// with units "days since ..." or "hours since ..."
+// without calendar attribute, the calendar is standard
+func (nc *ncFile) timeAxis(v *ncVar) (years, doys []int, calendar Calendar, err error) {
+	timeName := nc.dims[v.dimIds[0]].name
+	timeVar, ok := nc.vars[timeName]
+	if !ok {
+		return nil, nil, "", fmt.Errorf("%s: no time coordinate variable %s", nc.name, timeName)
+	}
+	calendar, err = parseCalendar(timeVar.attributeText("calendar"))
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("%s: %w", nc.name, err)
+	}
+	if calendar == "" {
+		calendar = CalendarStandard
+	}
+	unit, origin, err := parseTimeUnits(timeVar.attributeText("units"))
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("%s: %w", nc.name, err)
+	}
+	values, err := nc.readAll(timeVar)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	years = make([]int, len(values))
+	doys = make([]int, len(values))
+	if calendar.isStandard() {
+		for t, value := range values {
+			date := origin.Add(time.Duration(value * float64(unit)))
+			years[t] = date.Year()
+			doys[t] = date.YearDay()
+		}
+		return years, doys, calendar, nil
+	}
+	// origin date in the calendar, time steps as whole days after the origin date
+	originDoy, err := calendar.dayOfYear(origin.Year(), int(origin.Month()), origin.Day())
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("%s: time units: %w", nc.name, err)
+	}
+	originTime := time.Duration(origin.Hour())*time.Hour + time.Duration(origin.Minute())*time.Minute + time.Duration(origin.Second())*time.Second
+	for t, value := range values {
+		days := int(math.Floor((float64(originTime) + value*float64(unit)) / float64(24*time.Hour)))
+		years[t], doys[t] = calendar.addDays(origin.Year(), originDoy, days)
+	}
+	return years, doys, calendar, nil
+}
+
+// parse CF time units, e.g. "days since 1949-12-01 00:00:00"
+func parseTimeUnits(units string) (unit time.Duration, origin time.Time, err error) {
+	fields := strings.Fields(units)
+	if len(fields) < 3 || fields[1] != "since" {
+		return 0, origin, fmt.Errorf("time units %q: expected <unit> since <date>", units)
+	}
+	switch fields[0] {
+	case "days", "day", "d":
+		unit = 24 * time.Hour
+	case "hours", "hour", "h":
+		unit = time.Hour
+	default:
+		return 0, origin, fmt.Errorf("time units %q: unit %s is not supported", units, fields[0])
+	}
+	// date with optional time, ISO 8601 "T" separator is accepted
+	reference := strings.Join(fields[2:], " ")
+	reference = strings.TrimSuffix(strings.Replace(reference, "T", " ", 1), "Z")
+	for _, layout := range []string{"2006-1-2 15:4:5", "2006-1-2 15:4", "2006-1-2"} {
+		origin, err = time.Parse(layout, reference)
+		if err == nil {
+			return unit, origin, nil
+		}
+	}
+	return 0, origin, fmt.Errorf("time units %q: %w", units, err)
+}
+
+// convert values to the units of the weather records, unknown units are not converted
+func convertUnits(values []float64, units string) {
+	factor, offset, _ := unitConversion(units)
+	if factor == 1 && offset == 0 {
+		return
+	}
+	for i := range values {
+		values[i] = values[i]*factor + offset
+	}
+}
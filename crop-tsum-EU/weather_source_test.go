@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// variable of a NetCDF test file, all variables are record variables (first dimension time)
+type testNcVar struct {
+	name     string
+	dimIds   []int
+	attrs    map[string]interface{} // string or []float64 (stored as double)
+	dataType int
+	data     []float64 // values of all records
+}
+
+// encode a NetCDF classic file with record dimension time (dimension 0)
+func encodeTestNetCDF(numRecs int, dims []ncDim, vars []testNcVar) []byte {
+	be := binary.BigEndian
+	putInt := func(buf *bytes.Buffer, v int) { binary.Write(buf, be, int32(v)) }
+	putName := func(buf *bytes.Buffer, name string) {
+		putInt(buf, len(name))
+		buf.WriteString(name)
+		buf.Write(make([]byte, (4-len(name)%4)%4))
+	}
+	putAttrs := func(buf *bytes.Buffer, attrs map[string]interface{}) {
+		if len(attrs) == 0 {
+			putInt(buf, 0)
+			putInt(buf, 0)
+			return
+		}
+		putInt(buf, ncTagAttribute)
+		putInt(buf, len(attrs))
+		for _, name := range []string{"units", "calendar", "scale_factor", "add_offset", "_FillValue"} {
+			value, ok := attrs[name]
+			if !ok {
+				continue
+			}
+			putName(buf, name)
+			switch v := value.(type) {
+			case string:
+				putInt(buf, ncChar)
+				putName(buf, v)
+			case []float64:
+				putInt(buf, ncDouble)
+				putInt(buf, len(v))
+				binary.Write(buf, be, v)
+			}
+		}
+	}
+	cellCount := func(v testNcVar) int {
+		n := 1
+		for _, dimId := range v.dimIds[1:] {
+			n *= dims[dimId].length
+		}
+		return n
+	}
+	vsize := func(v testNcVar) int {
+		size, _ := ncTypeSize(v.dataType)
+		return (cellCount(v)*size + 3) / 4 * 4
+	}
+	header := func(begin int) []byte {
+		buf := &bytes.Buffer{}
+		buf.WriteString("CDF\x01")
+		putInt(buf, numRecs)
+		putInt(buf, ncTagDimension)
+		putInt(buf, len(dims))
+		for _, dim := range dims {
+			putName(buf, dim.name)
+			putInt(buf, dim.length)
+		}
+		putInt(buf, 0)
+		putInt(buf, 0)
+		putInt(buf, ncTagVariable)
+		putInt(buf, len(vars))
+		for _, v := range vars {
+			putName(buf, v.name)
+			putInt(buf, len(v.dimIds))
+			for _, dimId := range v.dimIds {
+				putInt(buf, dimId)
+			}
+			putAttrs(buf, v.attrs)
+			putInt(buf, v.dataType)
+			putInt(buf, vsize(v))
+			putInt(buf, begin)
+			begin += vsize(v)
+		}
+		return buf.Bytes()
+	}
+	data := header(len(header(0)))
+	buf := bytes.NewBuffer(data)
+	for rec := 0; rec < numRecs; rec++ {
+		for _, v := range vars {
+			n := cellCount(v)
+			start := buf.Len()
+			for _, value := range v.data[rec*n : (rec+1)*n] {
+				switch v.dataType {
+				case ncShort:
+					binary.Write(buf, be, int16(value))
+				case ncFloat:
+					binary.Write(buf, be, float32(value))
+				case ncDouble:
+					binary.Write(buf, be, value)
+				}
+			}
+			buf.Write(make([]byte, vsize(v)-(buf.Len()-start)))
+		}
+	}
+	return buf.Bytes()
+}
+
+func Test_netcdfWeatherSource(t *testing.T) {
+	// 3 days, 2 x 3 grid, grid code 2_3 is the last cell
+	dims := []ncDim{{"time", 0}, {"y", 2}, {"x", 3}}
+	field := func(last ...float64) []float64 {
+		values := make([]float64, 0, 18)
+		for _, v := range last {
+			values = append(values, 0, 0, 0, 0, 0, v)
+		}
+		return values
+	}
+	vars := []testNcVar{
+		{"time", []int{0}, map[string]interface{}{"units": "days since 2000-12-30 00:00:00", "calendar": "standard"}, ncDouble, []float64{0, 1, 2}},
+		{"tas", []int{0, 1, 2}, map[string]interface{}{"units": "K"}, ncFloat, field(283.15, 273.15, 263.15)},
+		{"tasmin", []int{0, 1, 2}, map[string]interface{}{"units": "degC", "scale_factor": []float64{0.1}, "add_offset": []float64{0}, "_FillValue": []float64{-999}}, ncShort, field(55, -999, -120)},
+		{"pr", []int{0, 1, 2}, map[string]interface{}{"units": "kg m-2 s-1"}, ncFloat, field(0, 1.0/86400, 0)},
+	}
+	fileName := filepath.Join(t.TempDir(), "weather.nc")
+	if err := os.WriteFile(fileName, encodeTestNetCDF(3, dims, vars), 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, err := newWeatherSource(fileName, nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+
+	days, err := source.Read("2_3", 2000, 2001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []weatherDay{
+		{year: 2000, doy: 365, tavg: 10, tmin: 5.5, precip: 0},
+		{year: 2000, doy: 366, tavg: 0, tmin: math.NaN(), precip: 1},
+		{year: 2001, doy: 1, tavg: -10, tmin: -12, precip: 0},
+	}
+	if len(days) != len(want) {
+		t.Fatalf("Read() returned %d days, want %d", len(days), len(want))
+	}
+	equal := func(a, b float64) bool {
+		return (math.IsNaN(a) && math.IsNaN(b)) || math.Abs(a-b) < 1e-4
+	}
+	for i, day := range days {
+		w := want[i]
+		if day.year != w.year || day.doy != w.doy || !equal(day.tavg, w.tavg) || !equal(day.tmin, w.tmin) || !equal(day.precip, w.precip) {
+			t.Errorf("day %d = %+v, want %+v", i, day, w)
+		}
+		if !math.IsNaN(day.tmax) {
+			t.Errorf("day %d: tmax = %v, want NaN without tasmax", i, day.tmax)
+		}
+	}
+	// year filter
+	if days, _ := source.Read("2_3", 2001, 2001); len(days) != 1 {
+		t.Errorf("Read() 2001 returned %d days, want 1", len(days))
+	}
+	// grid code outside of the grid
+	if _, err := source.Read("3_1", 2000, 2001); err == nil {
+		t.Errorf("Read() outside of grid, want error")
+	}
+
+	// 360_day calendar, the scenario calendar must match the calendar of the file
+	vars[0].attrs = map[string]interface{}{"units": "days since 2000-12-29 12:00:00", "calendar": "360_day"}
+	fileName = filepath.Join(t.TempDir(), "weather360.nc")
+	if err := os.WriteFile(fileName, encodeTestNetCDF(3, dims, vars), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newWeatherSource(fileName, nil, CalendarStandard, nil); err == nil {
+		t.Errorf("newWeatherSource() with standard calendar for 360_day file, want error")
+	}
+	source360, err := newWeatherSource(fileName, nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source360.Close()
+	if source360.Calendar() != Calendar360Day {
+		t.Errorf("Calendar() = %s, want %s", source360.Calendar(), Calendar360Day)
+	}
+	days, err = source360.Read("2_3", 2000, 2001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 3 || days[0].doy != 359 || days[1].doy != 360 || days[2].year != 2001 || days[2].doy != 1 {
+		t.Errorf("Read() 360_day = %+v, want DOY 359, 360 of 2000 and DOY 1 of 2001", days)
+	}
+}
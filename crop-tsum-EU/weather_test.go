@@ -0,0 +1,319 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_readWeatherFile(t *testing.T) {
+	nan := math.NaN()
+	headerLines := 1
+	dwd := &WeatherFormat{
+		HeaderLines: &headerLines,
+		Delimiter:   ";",
+		DateFormat:  "YYYYMMDD",
+		Columns: map[string][]string{
+			"date":   {"MESS_DATUM"},
+			"tavg":   {"TMK"},
+			"tmin":   {"TNK"},
+			"precip": {"RSK"},
+		},
+		Units: map[string]string{"tavg": "K"},
+	}
+	if err := dwd.validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		format  *WeatherFormat
+		columns *weatherColumns
+		content string
+		scanAll bool
+		want    []weatherDay
+		wantErr string
+	}{
+		{
+			name:    "default format",
+			content: "iso-date,tmin,tavg,precip\n[],[C],[C],[mm]\n1999-12-31,1,2,3\n2000-01-01,4,5,6\n2000-01-02,7,8,9\n",
+			want:    []weatherDay{{year: 2000, doy: 1, tavg: 5, tmin: 4, tmax: nan, precip: 6}, {year: 2000, doy: 2, tavg: 8, tmin: 7, tmax: nan, precip: 9}},
+		},
+		{
+			name:    "dwd station",
+			format:  dwd,
+			content: "STATIONS_ID;MESS_DATUM; RSK; TMK; TNK;eor\n   433;20000301;  0.5; 280.15;  2.0;eor\n",
+			want:    []weatherDay{{year: 2000, doy: 61, tavg: 7, tmin: 2, tmax: nan, precip: 0.5}},
+		},
+		{
+			name:    "stop after end year",
+			content: "iso-date,tmin,tavg,precip\n[],[C],[C],[mm]\n2000-01-01,4,5,6\n2001-01-01,1,2,3\n2000-01-02,7,8,9\n",
+			want:    []weatherDay{{year: 2000, doy: 1, tavg: 5, tmin: 4, tmax: nan, precip: 6}},
+		},
+		{
+			name:    "scan all lines",
+			content: "iso-date,tmin,tavg,precip\n[],[C],[C],[mm]\n2000-01-01,4,5,6\n2001-01-01,1,2,3\n2000-01-02,7,8,9\n",
+			scanAll: true,
+			want:    []weatherDay{{year: 2000, doy: 1, tavg: 5, tmin: 4, tmax: nan, precip: 6}, {year: 2000, doy: 2, tavg: 8, tmin: 7, tmax: nan, precip: 9}},
+		},
+		{
+			name:    "missing column",
+			content: "iso-date,tmin,precip\n[],[C],[mm]\n2000-01-01,4,6\n",
+			wantErr: "missing column tavg",
+		},
+		{
+			name:    "unused column not read",
+			columns: newWeatherColumns([]*Crop{{}}, nil),
+			content: "iso-date,tmin,tavg,tmax,precip,globrad\n[],[C],[C],[C],[mm],[MJ]\n2000-01-01,4,5,x,6,x\n",
+			want:    []weatherDay{{year: 2000, doy: 1, tavg: 5, tmin: 4, tmax: nan, precip: 6}},
+		},
+		{
+			name:    "used column read",
+			columns: newWeatherColumns([]*Crop{{HeatStress: &HeatStress{Threshold: 30}}}, nil),
+			content: "iso-date,tmin,tavg,tmax,precip,globrad\n[],[C],[C],[C],[mm],[MJ]\n2000-01-01,4,5,x,6,x\n",
+			wantErr: "line 3: tmax",
+		},
+		{
+			name:    "all columns read with scan all",
+			columns: newWeatherColumns([]*Crop{{}}, nil),
+			content: "iso-date,tmin,tavg,tmax,precip,globrad\n[],[C],[C],[C],[mm],[MJ]\n2000-01-01,4,5,8,6,x\n",
+			scanAll: true,
+			wantErr: "line 3: globrad",
+		},
+		{
+			name:    "derived tavg without tavg column",
+			columns: newWeatherColumns(nil, &MissingPolicy{DeriveTavg: true}),
+			content: "iso-date,tmin,tmax,precip\n[],[C],[C],[mm]\n2000-01-01,4,8,6\n",
+			want:    []weatherDay{{year: 2000, doy: 1, tavg: nan, tmin: 4, tmax: 8, precip: 6}},
+		},
+		{
+			name:    "derived tavg without tmax column",
+			columns: newWeatherColumns(nil, &MissingPolicy{DeriveTavg: true}),
+			content: "iso-date,tmin,tavg,precip\n[],[C],[C],[mm]\n2000-01-01,4,5,6\n",
+			wantErr: "missing column tmax",
+		},
+		{
+			name:    "short line",
+			content: "iso-date,tmin,tavg,precip\n[],[C],[C],[mm]\n2000-01-01,4,5\n",
+			wantErr: "line 3: missing field precip",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "weather.csv")
+			if err := os.WriteFile(fileName, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readWeatherFile(fileName, tt.format, CalendarStandard, tt.columns, 2000, 2000, tt.scanAll)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("readWeatherFile() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("readWeatherFile() returned %d days, want %d", len(got), len(tt.want))
+			}
+			equal := func(a, b float64) bool {
+				return math.Abs(a-b) < 1e-9 || (math.IsNaN(a) && math.IsNaN(b))
+			}
+			for i, day := range got {
+				w := tt.want[i]
+				if day.year != w.year || day.doy != w.doy || !equal(day.tavg, w.tavg) ||
+					day.tmin != w.tmin || !equal(day.tmax, w.tmax) || day.precip != w.precip {
+					t.Errorf("day %d = %+v, want %+v", i, day, w)
+				}
+			}
+		})
+	}
+}
+
+func Test_readWeatherFormat(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		wantHeaderLines int
+		wantErr         bool
+	}{
+		{"defaults", "delimiter: ;\n", 2, false},
+		{"one header line", "headerlines: 1\n", 1, false},
+		{"no header lines", "headerlines: 0\n", 0, true},
+		{"negative header lines", "headerlines: -1\n", 0, true},
+		{"unknown column", "columns:\n  tmean: [TMK]\n", 0, true},
+		{"unknown units", "units:\n  tavg: F\n", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "format.yml")
+			if err := os.WriteFile(fileName, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			format, err := readWeatherFormat(fileName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readWeatherFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *format.HeaderLines != tt.wantHeaderLines {
+				t.Errorf("HeaderLines = %d, want %d", *format.HeaderLines, tt.wantHeaderLines)
+			}
+		})
+	}
+}
+
+func Test_fillMissing(t *testing.T) {
+	nan := math.NaN()
+	// 4 days in each of 2 years
+	// tmax nil: no tmax in the weather data
+	newWeather := func(tavg, tmax []float64) []weatherDay {
+		weather := make([]weatherDay, len(tavg))
+		for i := range weather {
+			weather[i] = weatherDay{year: 2000 + i/4, doy: i%4 + 1, tavg: tavg[i], tmin: 0, tmax: nan, precip: 0, globrad: nan, wind: nan, relhumid: nan}
+			if tmax != nil {
+				weather[i].tmax = tmax[i]
+			}
+		}
+		return weather
+	}
+	allNaN := []float64{nan, nan, nan, nan, nan, nan, nan, nan}
+	tests := []struct {
+		name     string
+		policy   *MissingPolicy
+		tavg     []float64
+		tmax     []float64
+		tmaxUsed bool
+		want     []float64
+		wantErr  bool
+	}{
+		{"complete", nil, []float64{1, 2, 3, 4, 5, 6, 7, 8}, nil, false, []float64{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{"no policy", nil, []float64{1, nan, 3, 4, 5, 6, 7, 8}, nil, false, nil, true},
+		{"interpolate", &MissingPolicy{Interpolate: 2}, []float64{1, nan, nan, 4, 5, 6, 7, 8}, nil, false, []float64{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{"gap too long", &MissingPolicy{Interpolate: 1}, []float64{1, nan, nan, 4, 5, 6, 7, 8}, nil, false, nil, true},
+		{"climatology", &MissingPolicy{Climatology: true}, []float64{1, 2, 3, 4, 5, nan, 7, nan}, nil, false, []float64{1, 2, 3, 4, 5, 2, 7, 4}, false},
+		{"invalid year", &MissingPolicy{InvalidYear: true}, []float64{1, 2, 3, 4, nan, 6, 7, 8}, nil, false, []float64{1, 2, 3, 4, nan, 6, 7, 8}, false},
+		{"derive tavg", &MissingPolicy{DeriveTavg: true}, []float64{1, nan, 3, 4, 5, 6, 7, 8}, []float64{2, 4, 6, 8, 10, 12, 14, 16}, false, []float64{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{"derive all tavg", &MissingPolicy{DeriveTavg: true}, allNaN, []float64{2, 4, 6, 8, 10, 12, 14, 16}, false, []float64{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{"no tavg", &MissingPolicy{DeriveTavg: true, InvalidYear: true}, allNaN, nil, false, nil, true},
+		{"missing value of unused column", nil, []float64{1, 2, 3, 4, 5, 6, 7, 8}, []float64{2, nan, 6, 8, 10, 12, 14, 16}, false, []float64{1, 2, 3, 4, 5, 6, 7, 8}, false},
+		{"missing value of used column", nil, []float64{1, 2, 3, 4, 5, 6, 7, 8}, []float64{2, nan, 6, 8, 10, 12, 14, 16}, true, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			weather := newWeather(tt.tavg, tt.tmax)
+			columns := []int{colTavg, colTmin, colPrecip}
+			if tt.tmaxUsed {
+				columns = append(columns, colTmax)
+			}
+			err := fillMissing(weather, tt.policy, columns)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fillMissing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for i, day := range weather {
+				if day.tavg != tt.want[i] && !(math.IsNaN(day.tavg) && math.IsNaN(tt.want[i])) {
+					t.Errorf("day %d tavg = %v, want %v", i, day.tavg, tt.want[i])
+				}
+				if filled := math.IsNaN(tt.tavg[i]) && !math.IsNaN(tt.want[i]); day.filled != filled {
+					t.Errorf("day %d filled = %v, want %v", i, day.filled, filled)
+				}
+			}
+		})
+	}
+}
+
+func Test_filledWeatherSourceDeriveTavg(t *testing.T) {
+	dir := t.TempDir()
+	content := "iso-date,tmin,tmax,precip\n[],[C],[C],[mm]\n2000-01-01,4,8,6\n2000-01-02,-2,4,0\n"
+	if err := os.WriteFile(filepath.Join(dir, "g1.csv"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policy := &MissingPolicy{DeriveTavg: true}
+	source, err := newWeatherSource(filepath.Join(dir, "%s.csv"), nil, "", newWeatherColumns([]*Crop{testCrop()}, policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source = &filledWeatherSource{WeatherSource: source, crops: []*Crop{testCrop()}, policy: policy}
+	weather, err := source.Read("g1", 2000, 2000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []float64{6, 1} {
+		if weather[i].tavg != want || !weather[i].filled {
+			t.Errorf("day %d tavg = %v, filled %v, want %v, true", i, weather[i].tavg, weather[i].filled, want)
+		}
+	}
+	// without policy, tavg is required
+	source, err = newWeatherSource(filepath.Join(dir, "%s.csv"), nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := source.Read("g1", 2000, 2000); err == nil || !strings.Contains(err.Error(), "missing column tavg") {
+		t.Errorf("Read() error = %v, want missing column tavg", err)
+	}
+}
+
+func Test_usedSeasonColumns(t *testing.T) {
+	nan := math.NaN()
+	weather := []weatherDay{{tavg: 10, tmin: 5, tmax: 15, precip: 1, globrad: nan, wind: nan, relhumid: nan}}
+	pmWeather := []weatherDay{{tavg: 10, tmin: 5, tmax: 15, precip: 1, globrad: 20, wind: 2, relhumid: 70}}
+	tests := []struct {
+		name    string
+		crops   []*Crop
+		weather []weatherDay
+		want    []int
+	}{
+		{"tavg crop", []*Crop{{}}, pmWeather, []int{colTavg, colTmin, colPrecip}},
+		{"heat stress", []*Crop{{}, {HeatStress: &HeatStress{Threshold: 30}}}, weather, []int{colTavg, colTmin, colTmax, colPrecip}},
+		{"drought with Hargreaves", []*Crop{{Drought: &Drought{}}}, weather, []int{colTavg, colTmin, colTmax, colPrecip}},
+		{"drought with Penman-Monteith", []*Crop{{Drought: &Drought{}}}, pmWeather, []int{colTavg, colTmin, colTmax, colPrecip, colGlobrad, colWind, colRelhumid}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usedSeasonColumns(tt.crops, tt.weather); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("usedSeasonColumns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkSeason(t *testing.T) {
+	nan := math.NaN()
+	// 30 days, season from day 5 to day 15, wet harvest window up to day 25
+	newSetup := func(precipNaN int) *seasonSetup {
+		weather := make([]weatherDay, 30)
+		for i := range weather {
+			weather[i] = weatherDay{year: 2000, doy: i + 1, tavg: 10, tmin: 5, tmax: nan, precip: 1}
+		}
+		if precipNaN >= 0 {
+			weather[precipNaN].precip = nan
+		}
+		return &seasonSetup{weather: weather, harvestRain: defaultHarvestRainRule(), missingColumns: []int{colPrecip}}
+	}
+	season := seasonRange{first: 4, last: 14}
+	tests := []struct {
+		name      string
+		precipNaN int
+		want      bool
+	}{
+		{"complete", -1, true},
+		{"before season", 2, true},
+		{"in season", 10, false},
+		{"wet harvest window", 20, false},
+		{"after wet harvest window", 26, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := newSetup(tt.precipNaN).checkSeason(season); got != tt.want {
+				t.Errorf("checkSeason() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
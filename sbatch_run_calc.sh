@@ -6,105 +6,16 @@
 #SBATCH --ntasks=1
 #SBATCH --cpus-per-task=80
 
-# climate scenarios
-CLIMATE=$1 # climate scenario root folder
-GRID_REF=$2 # grid to reference file folder
-CROP=$3 #crop
-
-# remove ext from crop file
-CROPOUT=${CROP%.*}
+# climate scenarios and grid files are referenced in run_manifest.yml
+# as ${CLIMATE} and ${GRID_REF}
+export CLIMATE=$1 # climate scenario root folder
+export GRID_REF=$2 # grid to reference file folder
+CROP=$3 #crop file(s), comma separated, or a folder with crop files
 
 PROGRAM=./crop-tsum-EU/crop-tsum-EU
-mkdir -p $CROPOUT
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates/0_0_sowing-dates.csv.gz \
--weather ${CLIMATE}/0/0_0/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/historical &
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates/GFDL-CM3_45_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/GFDL-CM3_45/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_GFDL-CM3_45 &
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates/GFDL-CM3_85_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/GFDL-CM3_85/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_GFDL-CM3_85 &
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates/GISS-E2-R_45_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/GISS-E2-R_45/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_GISS-E2-R_45 &
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates/GISS-E2-R_85_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/GISS-E2-R_85/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_GISS-E2-R_85 &
-
 
+# runs all scenarios of the manifest, output is written to crops/<crop>/<scenario>
 ${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates/HadGEM2-ES_45_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/HadGEM2-ES_45/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_HadGEM2-ES_45 &
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates/HadGEM2-ES_85_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/HadGEM2-ES_85/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_HadGEM2-ES_85 &
-
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates\MIROC5_45_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/MIROC5_45/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_MIROC5_45 &
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates\MIROC5_85_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/MIROC5_85/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_MIROC5_85 &
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates\MPI-ESM-MR_45_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/MPI-ESM-MR_45/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_MPI-ESM-MR_45 &
-
-${PROGRAM} \
--crop ${CROP} \
--sowing sowing_dates\MPI-ESM-MR_85_sowing-dates.csv.gz \
--weather ${CLIMATE}/2/MPI-ESM-MR_85/%s_v3.csv \
--reference ${GRID_REF}/stu_eu_layer_ref.csv \
--grid_to_ref ${GRID_REF}/stu_eu_layer_grid.csv \
--output $CROPOUT/2_MPI-ESM-MR_85 &
-
-wait
+-manifest run_manifest.yml \
+-crops "${CROP}" \
+-workers ${SLURM_CPUS_PER_TASK:-80}